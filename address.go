@@ -0,0 +1,138 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NFSServerAddress is the server side of an NFS mount, as parsed from the Device
+// field and cross checked against the mount options.
+type NFSServerAddress struct {
+  Host     string // server host as written in the device, brackets stripped from IPv6
+  Port     uint64 // from the `port=` option, 0 when the default (2049) is used
+  Addr     string // server IP the kernel resolved, from `addr=` or `mountaddr=`, if present
+  Mismatch bool   // true when Host is an IP literal that disagrees with Addr
+}
+
+// Key returns the best identifier of the server for grouping mounts together.
+// This is Addr when the options carry it, since that's the address the kernel
+// actually talks to no matter if the mount used a hostname or an IP, and Host
+// otherwise. IP addresses are normalized so that different spellings of the
+// same IPv6 address group together.
+func (a NFSServerAddress) Key() string {
+  if a.Addr != "" {
+    return normalizeIP(a.Addr)
+  }
+
+  return normalizeIP(a.Host)
+}
+
+// String returns the host in the form it would be written in a device, with
+// IPv6 addresses in brackets, eg: `[fd00::1]`.
+func (a NFSServerAddress) String() string {
+  if strings.Contains(a.Host, ":") {
+    return "[" + a.Host + "]"
+  }
+
+  return a.Host
+}
+
+// ServerAddress parses the server part of the Device field, eg: `10.0.2.31` from
+// `10.0.2.31:/volume1/Public/docs`. IPv4 addresses, hostnames and bracketed
+// IPv6 addresses (`[fd00::1]:/export`) are supported.
+// If the NFS options carry the server address (`addr=`, or `mountaddr=` for v3)
+// it's recorded in Addr, and Mismatch is set if Host is an IP that differs from
+// it.
+// Returns an error if the Device isn't in `host:/path` form, which is the case
+// for any non-NFS device.
+func (d *MountDevice) ServerAddress() (NFSServerAddress, error) {
+  return newNFSServerAddress(d.Device, d.NFSInfo.Options)
+}
+
+// ServerAddress parses the server part of the Device field of a delta, the
+// same way as MountDevice.ServerAddress.
+func (d *MountDeviceDelta) ServerAddress() (NFSServerAddress, error) {
+  return newNFSServerAddress(d.Device, d.NFSInfo.Options)
+}
+
+// newNFSServerAddress parses the server out of `device`, cross checked against
+// the mount `options`.
+func newNFSServerAddress(device string, options NFSMountOptions) (NFSServerAddress, error) {
+  host, _, err := splitNFSDevice(device)
+  if err != nil {
+    return NFSServerAddress{}, err
+  }
+
+  addr := NFSServerAddress{Host: host}
+  if port, ok := options.Uint("port"); ok {
+    addr.Port = port
+  }
+  addr.Addr = options.Get("addr")
+  if addr.Addr == "" {
+    addr.Addr = options.Get("mountaddr")
+  }
+
+  // a hostname can't be checked without resolving it, which we won't do here,
+  // but an IP literal in the device should be what the kernel is using
+  hostIP := net.ParseIP(host)
+  if hostIP != nil && addr.Addr != "" && !hostIP.Equal(net.ParseIP(addr.Addr)) {
+    addr.Mismatch = true
+  }
+
+  return addr, nil
+}
+
+// ExportPath parses the export path part of the Device field, eg:
+// `/volume1/Public/docs` from `10.0.2.31:/volume1/Public/docs`.
+// Returns an error if the Device isn't in `host:/path` form.
+func (d *MountDevice) ExportPath() (string, error) {
+  _, export, err := splitNFSDevice(d.Device)
+  if err != nil {
+    return "", err
+  }
+
+  return export, nil
+}
+
+// splitNFSDevice splits an NFS device string into the server host and the
+// export path. Bracketed IPv6 hosts have their brackets removed.
+// Returns an error if the device isn't in `host:/path` form.
+func splitNFSDevice(device string) (string, string, error) {
+  var host, export string
+
+  if strings.HasPrefix(device, "[") {
+    // [fd00::1]:/export, the colons inside the brackets are part of the host
+    end := strings.Index(device, "]:")
+    if end < 0 {
+      return "", "", fmt.Errorf("malformed IPv6 device, missing `]:`: %v", device)
+    }
+    host = device[1:end]
+    export = device[end+2:]
+  } else {
+    // the export always starts with a slash, so the first `:/` is the split,
+    // even for hostnames or the odd unbracketed IPv6 address
+    idx := strings.Index(device, ":/")
+    if idx < 0 {
+      return "", "", fmt.Errorf("device is not in host:/path form: %v", device)
+    }
+    host = device[:idx]
+    export = device[idx+1:]
+  }
+
+  if host == "" || !strings.HasPrefix(export, "/") {
+    return "", "", fmt.Errorf("device is not in host:/path form: %v", device)
+  }
+
+  return host, export, nil
+}
+
+// normalizeIP returns the canonical form of an IP address, or `host` unchanged
+// if it isn't one.
+func normalizeIP(host string) string {
+  if ip := net.ParseIP(host); ip != nil {
+    return ip.String()
+  }
+
+  return host
+}
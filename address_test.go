@@ -0,0 +1,75 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerAddress(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  mounts, err := nfsmountstats.NewMountstats()
+  if err != nil {
+    t.Fatalf("error creating new Mountstats: %v", err)
+  }
+  nfsDeviceMap := mounts.GetNFSMountMap()
+
+  // nfsv4 mount, no server address in the opts
+  docs := nfsDeviceMap["/mnt/nfs1/docs"]
+  addr, err := docs.ServerAddress()
+  assert.NoError(t, err)
+  assert.Equal(t, "10.0.2.31", addr.Host)
+  assert.Equal(t, "", addr.Addr)
+  assert.Equal(t, "10.0.2.31", addr.Key())
+  export, err := docs.ExportPath()
+  assert.NoError(t, err)
+  assert.Equal(t, "/volume1/Public/docs", export)
+
+  // nfsv3 mount, mountaddr agrees with the device
+  addr, err = nfsDeviceMap["/mailhome6"].ServerAddress()
+  assert.NoError(t, err)
+  assert.Equal(t, "10.0.47.9", addr.Addr)
+  assert.False(t, addr.Mismatch)
+
+  // non nfs devices don't have a server
+  _, err = mounts.Devices[0].ServerAddress()
+  assert.Error(t, err)
+}
+
+func TestServerAddressForms(t *testing.T) {
+  dev := nfsmountstats.MountDevice{Device: "[fd00::0001]:/export/home"}
+  dev.NFSInfo.Options = nfsmountstats.ParseNFSMountOptions("rw,vers=4.1,port=20049,addr=fd00::1")
+  addr, err := dev.ServerAddress()
+  assert.NoError(t, err)
+  assert.Equal(t, "fd00::0001", addr.Host)
+  assert.Equal(t, uint64(20049), addr.Port)
+  assert.False(t, addr.Mismatch)
+  assert.Equal(t, "fd00::1", addr.Key())
+  assert.Equal(t, "[fd00::0001]", addr.String())
+  export, err := dev.ExportPath()
+  assert.NoError(t, err)
+  assert.Equal(t, "/export/home", export)
+
+  // hostnames key on the address the kernel resolved when it's known
+  dev = nfsmountstats.MountDevice{Device: "filer01.example.com:/"}
+  dev.NFSInfo.Options = nfsmountstats.ParseNFSMountOptions("rw,vers=3,mountaddr=10.0.47.9")
+  addr, err = dev.ServerAddress()
+  assert.NoError(t, err)
+  assert.Equal(t, "filer01.example.com", addr.Host)
+  assert.Equal(t, "10.0.47.9", addr.Key())
+
+  // an ip in the device that isn't the one in the options
+  dev = nfsmountstats.MountDevice{Device: "10.0.47.9:/mailserver25home6"}
+  dev.NFSInfo.Options = nfsmountstats.ParseNFSMountOptions("rw,vers=3,mountaddr=10.0.47.10")
+  addr, err = dev.ServerAddress()
+  assert.NoError(t, err)
+  assert.True(t, addr.Mismatch)
+
+  for _, bad := range []string{"10.0.2.31", "[fd00::1:/export", ":/export", "tmpfs"} {
+    dev = nfsmountstats.MountDevice{Device: bad}
+    _, err = dev.ServerAddress()
+    assert.Error(t, err, bad)
+  }
+}
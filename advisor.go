@@ -0,0 +1,283 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IDs of the recommendations made by Advisor
+const (
+  AdviceRaiseRsize  = "raise-rsize"  // READs are as large as rsize allows
+  AdviceRaiseWsize  = "raise-wsize"  // WRITEs are as large as wsize allows
+  AdviceNconnect    = "nconnect"     // requests wait on the client for a connection or a slot
+  AdviceAcregmin    = "acregmin"     // GETATTRs revalidating cached attributes dominate
+  AdviceNoac        = "noac"         // attribute caching is off and GETATTRs dominate
+  AdviceLookupcache = "lookupcache"  // LOOKUPs dominate with the lookup cache limited
+  AdviceHard        = "hard"         // a soft mount is writing, errors can lose data
+  AdviceTCP         = "tcp"          // the mount is over UDP
+)
+
+// the largest rsize and wsize the Linux client allows, and nconnect
+const (
+  maxIOSize   = 1048576
+  maxNconnect = 16
+)
+
+// Recommendation is a single change to the mount options of a mount, with the
+// counters that call for it.
+type Recommendation struct {
+  ID         string // what to change, one of the Advice* constants
+  Device     string
+  Mountpoint string
+  Option     string // the mount option to change, eg: `rsize`
+  Current    string // its current value, "" if it isn't set or is a flag like `soft`
+  Suggested  string // the option to mount with instead, eg: `rsize=1048576`, "" to remove the option
+  Message    string // human readable reasoning
+  Evidence   []Evidence
+}
+
+// Evidence is a counter, or a value computed from them, behind a
+// Recommendation.
+type Evidence struct {
+  Name      string  // eg: `READ average size`
+  Value     float64
+  Threshold float64 // what Value was compared to, 0 if it wasn't
+}
+
+// Advisor recommends mount option changes from the options a mount was made
+// with and what it's doing. It only ever suggests; whether a change is safe
+// depends on the server and the applications as well.
+type Advisor struct {
+  MinOps       uint64  // ops that ran fewer times than this aren't judged
+  PinnedSize   float64 // average READ or WRITE size as a ratio of rsize or wsize that's pinned at it
+  Backlog      float64 // average requests waiting for a slot that calls for nconnect
+  QueueShare   float64 // share of request time queued on the client that calls for nconnect
+  GetattrShare float64 // GETATTR as a share of ops that calls for longer attribute caching
+  LookupShare  float64 // LOOKUP as a share of ops that calls for the full lookup cache
+}
+
+// NewAdvisor constructs an Advisor with the defaults: sizes pinned at 90% of
+// rsize or wsize, a backlog of one request or 10% of the time queued, and
+// GETATTRs or LOOKUPs a third of all ops, for ops that ran at least 100 times.
+func NewAdvisor() *Advisor {
+  return &Advisor{
+    MinOps: 100,
+    PinnedSize: 0.9,
+    Backlog: 1,
+    QueueShare: 0.1,
+    GetattrShare: 0.33,
+    LookupShare: 0.33,
+  }
+}
+
+// Advise advises on every device of the delta, ordered by mountpoint and ID.
+func (a *Advisor) Advise(delta *MountstatsDelta) []Recommendation {
+  var recs []Recommendation
+  for idx := range delta.Devices {
+    recs = append(recs, a.AdviseDevice(&delta.Devices[idx])...)
+  }
+  sort.SliceStable(recs, func(i, j int) bool {
+    if recs[i].Mountpoint != recs[j].Mountpoint {
+      return recs[i].Mountpoint < recs[j].Mountpoint
+    }
+    return recs[i].ID < recs[j].ID
+  })
+
+  return recs
+}
+
+// AdviseDevice advises on a single device from what it did over the Interval
+// of the delta.
+func (a *Advisor) AdviseDevice(d *MountDeviceDelta) []Recommendation {
+  return a.advise(d.Device, d.Mountpoint, &d.NFSInfo, d.Interval, d.sinceMount())
+}
+
+// AdviseMount advises on a single device from what it did since it was
+// mounted, which is what's available without two snapshots.
+func (a *Advisor) AdviseMount(d *MountDevice) []Recommendation {
+  return a.advise(d.Device, d.Mountpoint, &d.NFSInfo, time.Duration(d.NFSInfo.Age)*time.Second, true)
+}
+
+// advise runs every rule over the counters in `info`, which are either
+// cumulative or a delta, over `sample`.
+func (a *Advisor) advise(device, mountpoint string, info *NFSInfo, sample time.Duration, sinceMount bool) []Recommendation {
+  var recs []Recommendation
+  add := func(rec Recommendation) {
+    rec.Device, rec.Mountpoint = device, mountpoint
+    rec.Current = info.Options.Get(rec.Option)
+    recs = append(recs, rec)
+  }
+
+  var ops uint64
+  for _, s := range info.RPCOpStats {
+    ops += s.Operations
+  }
+  read := info.RPCOpStats["READ"]
+  write := info.RPCOpStats["WRITE"]
+  getattr := info.RPCOpStats["GETATTR"]
+  lookup := info.RPCOpStats["LOOKUP"]
+  workload := info.ClassifyWorkload()
+
+  // I/O that's as large as it can be would likely be larger if it could
+  if read.Operations >= a.MinOps && workload.Rsize > 0 && workload.Rsize < maxIOSize && workload.ReadSizeRatio >= a.PinnedSize {
+    add(Recommendation{
+      ID: AdviceRaiseRsize,
+      Option: "rsize",
+      Suggested: fmt.Sprintf("rsize=%d", maxIOSize),
+      Message: fmt.Sprintf("READs average %.0f bytes against an rsize of %d, larger reads would take fewer round trips", workload.AvgReadSize, workload.Rsize),
+      Evidence: []Evidence{
+        {Name: "READ ops", Value: float64(read.Operations)},
+        {Name: "READ average size", Value: workload.AvgReadSize, Threshold: a.PinnedSize * float64(workload.Rsize)},
+      },
+    })
+  }
+  if write.Operations >= a.MinOps && workload.Wsize > 0 && workload.Wsize < maxIOSize && workload.WriteSizeRatio >= a.PinnedSize {
+    add(Recommendation{
+      ID: AdviceRaiseWsize,
+      Option: "wsize",
+      Suggested: fmt.Sprintf("wsize=%d", maxIOSize),
+      Message: fmt.Sprintf("WRITEs average %.0f bytes against a wsize of %d, larger writes would take fewer round trips", workload.AvgWriteSize, workload.Wsize),
+      Evidence: []Evidence{
+        {Name: "WRITE ops", Value: float64(write.Operations)},
+        {Name: "WRITE average size", Value: workload.AvgWriteSize, Threshold: a.PinnedSize * float64(workload.Wsize)},
+      },
+    })
+  }
+
+  // requests waiting on the client for a slot or the connection
+  protocol := ""
+  if info.Transport != nil {
+    protocol = info.Transport.Protocol()
+  }
+  c := newConcurrency(info, sample, sinceMount)
+  var queueShare float64
+  if c.Outstanding > 0 {
+    queueShare = c.Queued / c.Outstanding
+  }
+  if protocol == "tcp" && ops >= a.MinOps && c.Nconnect < maxNconnect && (c.AvgBacklog >= a.Backlog || (c.Outstanding >= 1 && queueShare >= a.QueueShare)) {
+    add(Recommendation{
+      ID: AdviceNconnect,
+      Option: "nconnect",
+      Suggested: fmt.Sprintf("nconnect=%d", min(max(c.Nconnect*2, 4), maxNconnect)),
+      Message: fmt.Sprintf("requests wait on the client with %d connection(s) to the server, more connections spread them out", c.Nconnect),
+      Evidence: []Evidence{
+        {Name: "average backlog", Value: c.AvgBacklog, Threshold: a.Backlog},
+        {Name: "average requests outstanding", Value: c.Outstanding},
+        {Name: "share of time queued", Value: queueShare, Threshold: a.QueueShare},
+      },
+    })
+  }
+
+  // GETATTRs are mostly the client checking its cached attributes are fresh
+  getattrShare := ratio(getattr.Operations, ops)
+  if getattr.Operations >= a.MinOps && getattrShare >= a.GetattrShare {
+    evidence := []Evidence{
+      {Name: "GETATTR ops", Value: float64(getattr.Operations)},
+      {Name: "GETATTR share of ops", Value: getattrShare, Threshold: a.GetattrShare},
+      {Name: "attribute invalidates", Value: float64(info.Events.AttrInvalidates)},
+    }
+    acregmin := attrCacheMin(info.Options)
+    switch {
+    case info.Options.Has("noac") || acregmin == 0:
+      option := "noac"
+      if !info.Options.Has("noac") {
+        option = "actimeo"
+        if info.Options.Has("acregmin") {
+          option = "acregmin"
+        }
+      }
+      add(Recommendation{
+        ID: AdviceNoac,
+        Option: option,
+        Message: fmt.Sprintf("attribute caching is off and GETATTR is %.0f%% of ops, turn it back on unless applications need to see every change at once", getattrShare*100),
+        Evidence: evidence,
+      })
+    case acregmin < attrCacheMax(info.Options):
+      suggested := min(max(acregmin*4, 15), attrCacheMax(info.Options))
+      add(Recommendation{
+        ID: AdviceAcregmin,
+        Option: "acregmin",
+        Suggested: fmt.Sprintf("acregmin=%d", suggested),
+        Message: fmt.Sprintf("GETATTR is %.0f%% of ops with attributes cached for at least %ds, caching them longer saves round trips if files don't change under other clients", getattrShare*100, acregmin),
+        Evidence: evidence,
+      })
+    }
+  }
+
+  // a limited lookup cache sends a LOOKUP for names it could have cached
+  lookupShare := ratio(lookup.Operations, ops)
+  if mode := info.Options.Get("lookupcache"); (mode == "none" || mode == "pos" || mode == "positive") && lookup.Operations >= a.MinOps && lookupShare >= a.LookupShare {
+    evidence := []Evidence{
+      {Name: "LOOKUP ops", Value: float64(lookup.Operations)},
+      {Name: "LOOKUP share of ops", Value: lookupShare, Threshold: a.LookupShare},
+    }
+    if info.HasErrStats {
+      evidence = append(evidence, Evidence{Name: "LOOKUP errors", Value: float64(lookup.ErrStats)})
+    }
+    add(Recommendation{
+      ID: AdviceLookupcache,
+      Option: "lookupcache",
+      Message: fmt.Sprintf("LOOKUP is %.0f%% of ops with lookupcache=%s, the full lookup cache saves round trips if directories don't change under other clients", lookupShare*100, mode),
+      Evidence: evidence,
+    })
+  }
+
+  // a soft mount gives up on a request after a major timeout, which for a
+  // write means data the application thinks it wrote is lost
+  if soft := info.Options.Has("soft") || info.Options.Has("softerr"); soft && write.Operations > 0 {
+    option := "soft"
+    if !info.Options.Has("soft") {
+      option = "softerr"
+    }
+    add(Recommendation{
+      ID: AdviceHard,
+      Option: option,
+      Suggested: "hard",
+      Message: fmt.Sprintf("%s mount did %d WRITEs, any that times out is lost, mount hard to wait for the server instead", option, write.Operations),
+      Evidence: []Evidence{
+        {Name: "WRITE ops", Value: float64(write.Operations)},
+        {Name: "WRITE major timeouts", Value: float64(write.MajorTimeouts)},
+      },
+    })
+  }
+
+  if protocol == "udp" && ops > 0 {
+    add(Recommendation{
+      ID: AdviceTCP,
+      Option: "proto",
+      Suggested: "proto=tcp",
+      Message: "the mount is over UDP, which retransmits whole requests on any loss and limits rsize and wsize, TCP is safer and faster",
+      Evidence: []Evidence{
+        {Name: "ops", Value: float64(ops)},
+      },
+    })
+  }
+
+  return recs
+}
+
+// attrCacheMin returns the minimum time in seconds file attributes are
+// cached for, from acregmin or actimeo, or the kernel default of 3.
+func attrCacheMin(options NFSMountOptions) uint64 {
+  return attrCacheOption(options, "acregmin", 3)
+}
+
+// attrCacheMax returns the maximum time in seconds file attributes are
+// cached for, from acregmax or actimeo, or the kernel default of 60.
+func attrCacheMax(options NFSMountOptions) uint64 {
+  return attrCacheOption(options, "acregmax", 60)
+}
+
+// attrCacheOption returns the value of `name`, or actimeo which sets them all,
+// or `fallback`.
+func attrCacheOption(options NFSMountOptions, name string, fallback uint64) uint64 {
+  if value, ok := options.Uint(name); ok {
+    return value
+  }
+  if value, ok := options.Uint("actimeo"); ok {
+    return value
+  }
+
+  return fallback
+}
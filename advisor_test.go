@@ -0,0 +1,111 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+// adviceIDs returns the IDs of `recs` in order.
+func adviceIDs(recs []nfsmountstats.Recommendation) []string {
+  var ids []string
+  for _, rec := range recs {
+    ids = append(ids, rec.ID)
+  }
+
+  return ids
+}
+
+func TestAdviseMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+  advisor := nfsmountstats.NewAdvisor()
+
+  // WRITE: 759295 ops averaging 16088 bytes with wsize=16384, GETATTR 36% of ops
+  recs := advisor.AdviseMount(findDevice(t, mounts, "/mailhome5"))
+  assert.Equal(t, []string{nfsmountstats.AdviceRaiseWsize, nfsmountstats.AdviceAcregmin}, adviceIDs(recs))
+  wsize := recs[0]
+  assert.Equal(t, "/mailhome5", wsize.Mountpoint)
+  assert.Equal(t, "wsize", wsize.Option)
+  assert.Equal(t, "16384", wsize.Current)
+  assert.Equal(t, "wsize=1048576", wsize.Suggested)
+  assert.Equal(t, "WRITE average size", wsize.Evidence[1].Name)
+  assert.InDelta(t, 16088.37, wsize.Evidence[1].Value, 0.01)
+  assert.InDelta(t, 14745.6, wsize.Evidence[1].Threshold, 0.01)
+
+  acregmin := recs[1]
+  assert.Equal(t, "3", acregmin.Current)
+  assert.Equal(t, "acregmin=15", acregmin.Suggested)
+  assert.InDelta(t, 0.355805, acregmin.Evidence[1].Value, 0.000001)
+
+  recs = advisor.AdviseMount(findDevice(t, mounts, "/mailhome5udp"))
+  assert.Contains(t, adviceIDs(recs), nfsmountstats.AdviceTCP)
+}
+
+// adviceDelta returns the delta of /mailhome6 over 10s mounted with `options`
+// where `op` ran 1000 times sending and receiving `bytes` each.
+func adviceDelta(t *testing.T, options string, op string, bytes uint64) *nfsmountstats.MountstatsDelta {
+  t.Helper()
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    dev.NFSInfo.Options = nfsmountstats.ParseNFSMountOptions(options)
+    s := dev.NFSInfo.RPCOpStats[op]
+    s.Operations += 1000
+    s.BytesSent += 1000 * bytes
+    s.BytesReceived += 1000 * bytes
+    s.CumTotalReqTime += 1000
+    dev.NFSInfo.RPCOpStats[op] = s
+  })
+
+  return delta
+}
+
+func TestAdvise(t *testing.T) {
+  advisor := nfsmountstats.NewAdvisor()
+
+  // reads pinned at rsize
+  recs := advisor.Advise(adviceDelta(t, "rw,vers=3,rsize=65536,hard", "READ", 65600))
+  assert.Equal(t, []string{nfsmountstats.AdviceRaiseRsize}, adviceIDs(recs))
+  assert.Equal(t, "rsize=1048576", recs[0].Suggested)
+
+  // but not when they're as large as they get, or well below rsize
+  assert.Empty(t, advisor.Advise(adviceDelta(t, "rw,vers=3,rsize=1048576,hard", "READ", 1048700)))
+  assert.Empty(t, advisor.Advise(adviceDelta(t, "rw,vers=3,rsize=65536,hard", "READ", 4096)))
+
+  // writing on a soft mount
+  recs = advisor.Advise(adviceDelta(t, "rw,vers=3,wsize=1048576,soft", "WRITE", 4096))
+  assert.Equal(t, []string{nfsmountstats.AdviceHard}, adviceIDs(recs))
+  assert.Equal(t, "soft", recs[0].Option)
+  assert.Equal(t, "hard", recs[0].Suggested)
+
+  recs = advisor.Advise(adviceDelta(t, "rw,vers=3,lookupcache=none", "LOOKUP", 100))
+  assert.Equal(t, []string{nfsmountstats.AdviceLookupcache}, adviceIDs(recs))
+  assert.Equal(t, "none", recs[0].Current)
+  assert.Equal(t, "", recs[0].Suggested)
+
+  recs = advisor.Advise(adviceDelta(t, "rw,vers=3,actimeo=0", "GETATTR", 100))
+  assert.Equal(t, []string{nfsmountstats.AdviceNoac}, adviceIDs(recs))
+  assert.Equal(t, "actimeo", recs[0].Option)
+
+  // attributes are already cached as long as they can be
+  assert.Empty(t, advisor.Advise(adviceDelta(t, "rw,vers=3,acregmin=60,acregmax=60", "GETATTR", 100)))
+}
+
+func TestAdviseNconnect(t *testing.T) {
+  advisor := nfsmountstats.NewAdvisor()
+
+  // 3 requests waiting for a slot for every send
+  delta := adviceDelta(t, "rw,vers=3,nconnect=2", "READ", 4096)
+  dev := delta.GetMountMap()["/mailhome6"]
+  xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+  xprt.RpcSends = 1000
+  xprt.BacklogUtil = 3000
+
+  recs := advisor.AdviseDevice(dev)
+  assert.Equal(t, []string{nfsmountstats.AdviceNconnect}, adviceIDs(recs))
+  assert.Equal(t, "2", recs[0].Current)
+  assert.Equal(t, "nconnect=4", recs[0].Suggested)
+  assert.Equal(t, 3.0, recs[0].Evidence[0].Value)
+}
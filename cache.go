@@ -0,0 +1,112 @@
+package nfsmountstats
+
+import (
+	"time"
+)
+
+// CacheStats describes how well the client side caches of a mount are working,
+// either since it was mounted or over an interval between two snapshots.
+// Ratios are between 0 and 1, and are 0 when there was nothing to divide by,
+// eg: the hit ratio of a mount nothing was read from.
+type CacheStats struct {
+  SampleTime time.Duration // the time the rates are averaged over
+
+  // page cache, from the bytes: counters
+  ApplicationReadBytes uint64  // read by applications with read(2), NormalReadBytes
+  DirectReadBytes      uint64  // read by applications with O_DIRECT, which skips the cache
+  ServerReadBytes      uint64  // read from the server with NFS READs, including O_DIRECT
+  ClientReadBytes      uint64  // read from the server to fill the page cache
+  CachedReadBytes      uint64  // read by applications without going to the server
+  PageCacheHitRatio    float64 // CachedReadBytes / ApplicationReadBytes
+
+  // attribute cache, from the events: counters
+  Opens                  uint64
+  InodeRevalidates       uint64
+  AttrInvalidates        uint64
+  DataInvalidates        uint64
+  RevalidationsPerOpen   float64 // inode revalidations for every open
+  AttrInvalidatesPerOpen float64
+  AttrInvalidatesPerSec  float64
+  DataInvalidatesPerSec  float64 // how often cached file data is thrown away
+
+  // directory entry cache and readdir, from the events: and per-op counters
+  Lookups               uint64  // nfs_lookup calls, names that weren't in the dentry cache
+  DentryRevalidates     uint64  // names found in the dentry cache that had to be checked
+  LookupRPCs            uint64  // LOOKUP ops sent to the server
+  DentryCacheHitRatio   float64 // DentryRevalidates / (DentryRevalidates + Lookups)
+  LookupRPCsPerLookup   float64
+  Readdirs              uint64  // nfs_readdir calls
+  ReaddirRPCs           uint64  // READDIR and READDIRPLUS ops sent to the server
+  ReaddirRPCsPerReaddir float64 // well below 1 when directory listings come from the cache
+}
+
+// CacheStats computes the cache stats from the counters since the mount was
+// made, with rates averaged over its Age.
+func (i *NFSInfo) CacheStats() CacheStats {
+  return newCacheStats(i, time.Duration(i.Age)*time.Second)
+}
+
+// CacheStats computes the cache stats over the Interval of the delta.
+func (d *MountDeviceDelta) CacheStats() CacheStats {
+  return newCacheStats(&d.NFSInfo, d.Interval)
+}
+
+// newCacheStats computes the cache stats of the counters in `info`, which are
+// either cumulative or a delta, over `sample`.
+func newCacheStats(info *NFSInfo, sample time.Duration) CacheStats {
+  e := info.Events
+  b := info.Bytes
+  seconds := sample.Seconds()
+
+  stats := CacheStats{
+    SampleTime: sample,
+    ApplicationReadBytes: b.NormalReadBytes,
+    DirectReadBytes: b.DirectReadBytes,
+    ServerReadBytes: b.ServerReadBytes,
+    // O_DIRECT reads show up in the server bytes but never went through the
+    // page cache. the counters aren't updated atomically together so this can
+    // come out negative on an O_DIRECT heavy mount, which is clamped to 0
+    ClientReadBytes: clampedSub(b.ServerReadBytes, b.DirectReadBytes),
+    Opens: e.VfsOpen,
+    InodeRevalidates: e.InodeRevalidates,
+    AttrInvalidates: e.AttrInvalidates,
+    DataInvalidates: e.DataInvalidates,
+    AttrInvalidatesPerSec: perSecond(e.AttrInvalidates, seconds),
+    DataInvalidatesPerSec: perSecond(e.DataInvalidates, seconds),
+    Lookups: e.VfsLookup,
+    DentryRevalidates: e.DentryRevalidates,
+    LookupRPCs: info.RPCOpStats["LOOKUP"].Operations,
+    Readdirs: e.VfsReaddir,
+    ReaddirRPCs: info.RPCOpStats["READDIR"].Operations + info.RPCOpStats["READDIRPLUS"].Operations,
+  }
+
+  // readahead can pull more from the server than applications asked for, in
+  // which case nothing was served from the cache
+  stats.CachedReadBytes = clampedSub(stats.ApplicationReadBytes, stats.ClientReadBytes)
+  stats.PageCacheHitRatio = ratio(stats.CachedReadBytes, stats.ApplicationReadBytes)
+  stats.RevalidationsPerOpen = ratio(e.InodeRevalidates, e.VfsOpen)
+  stats.AttrInvalidatesPerOpen = ratio(e.AttrInvalidates, e.VfsOpen)
+  stats.DentryCacheHitRatio = ratio(e.DentryRevalidates, e.DentryRevalidates+e.VfsLookup)
+  stats.LookupRPCsPerLookup = ratio(stats.LookupRPCs, e.VfsLookup)
+  stats.ReaddirRPCsPerReaddir = ratio(stats.ReaddirRPCs, e.VfsReaddir)
+
+  return stats
+}
+
+// clampedSub returns a - b, or 0 instead of wrapping around when b > a.
+func clampedSub(a, b uint64) uint64 {
+  if b > a {
+    return 0
+  }
+
+  return a - b
+}
+
+// ratio divides two counters, 0 when the denominator is 0.
+func ratio(numerator, denominator uint64) float64 {
+  if denominator == 0 {
+    return 0
+  }
+
+  return float64(numerator) / float64(denominator)
+}
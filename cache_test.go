@@ -0,0 +1,73 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheStatsSinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // bytes: 119180641567 7459840923 0 0 93848122978 7622270673 26459312 1932867
+  stats := findDevice(t, mounts, "/mailhome6").NFSInfo.CacheStats()
+  assert.Equal(t, 2919118*time.Second, stats.SampleTime)
+  assert.Equal(t, uint64(93848122978), stats.ClientReadBytes)
+  assert.Equal(t, uint64(25332518589), stats.CachedReadBytes)
+  assert.InDelta(t, 0.212555, stats.PageCacheHitRatio, 0.000001)
+
+  assert.Equal(t, uint64(5816728), stats.Opens)
+  assert.InDelta(t, 2.599191, stats.RevalidationsPerOpen, 0.000001)
+  assert.InDelta(t, 0.021079, stats.DataInvalidatesPerSec, 0.000001)
+
+  assert.InDelta(t, 0.947039, stats.DentryCacheHitRatio, 0.000001)
+  assert.InDelta(t, 1.005249, stats.LookupRPCsPerLookup, 0.000001)
+  assert.Equal(t, uint64(1368212), stats.ReaddirRPCs)
+  assert.InDelta(t, 0.546470, stats.ReaddirRPCsPerReaddir, 0.000001)
+}
+
+func TestCacheStatsUnderflow(t *testing.T) {
+  mounts := loadTestMountstats(t)
+  info := &findDevice(t, mounts, "/mailhome6").NFSInfo
+
+  // more O_DIRECT than went over the wire, and more from the server than the
+  // applications asked for, neither may wrap around
+  info.Bytes.DirectReadBytes = info.Bytes.ServerReadBytes + 1
+  stats := info.CacheStats()
+  assert.Equal(t, uint64(0), stats.ClientReadBytes)
+  assert.InDelta(t, 1.0, stats.PageCacheHitRatio, 0.000001)
+
+  info.Bytes.DirectReadBytes = 0
+  info.Bytes.ServerReadBytes = info.Bytes.NormalReadBytes * 2
+  stats = info.CacheStats()
+  assert.Equal(t, uint64(0), stats.CachedReadBytes)
+  assert.Equal(t, 0.0, stats.PageCacheHitRatio)
+
+  // nothing read or opened at all
+  info.Bytes.NormalReadBytes = 0
+  info.Events.VfsOpen = 0
+  stats = info.CacheStats()
+  assert.Equal(t, 0.0, stats.PageCacheHitRatio)
+  assert.Equal(t, 0.0, stats.RevalidationsPerOpen)
+}
+
+func TestCacheStatsInterval(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    dev.NFSInfo.Bytes.NormalReadBytes += 1000
+    dev.NFSInfo.Bytes.ServerReadBytes += 250
+    dev.NFSInfo.Events.VfsOpen += 10
+    dev.NFSInfo.Events.InodeRevalidates += 5
+    dev.NFSInfo.Events.DataInvalidates += 20
+  })
+
+  stats := delta.GetMountMap()["/mailhome6"].CacheStats()
+  assert.Equal(t, 10*time.Second, stats.SampleTime)
+  assert.InDelta(t, 0.75, stats.PageCacheHitRatio, 0.000001)
+  assert.InDelta(t, 0.5, stats.RevalidationsPerOpen, 0.000001)
+  assert.InDelta(t, 2.0, stats.DataInvalidatesPerSec, 0.000001)
+  assert.Equal(t, 0.0, stats.DentryCacheHitRatio)
+}
@@ -0,0 +1,262 @@
+package nfsmountstats
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+)
+
+// label keys set on MountDevice.Labels by NewNamespaceMountstats
+const (
+  LabelMountNamespace   = "mount_namespace"
+  LabelCgroup           = "cgroup"
+  LabelContainerID      = "container_id"
+  LabelContainerRuntime = "container_runtime"
+  LabelSystemdUnit      = "systemd_unit"
+)
+
+// MountOwner describes who a mount namespace, and therefore every mount inside
+// of it, belongs to. It's resolved from the cgroup path of a process in that
+// namespace.
+type MountOwner struct {
+  CgroupPath       string // the cgroup path the owner was resolved from
+  ContainerID      string // full container id, empty if not in a container
+  ContainerRuntime string // docker, containerd, crio, podman, lxc, or empty if unknown
+  SystemdUnit      string // the innermost systemd unit (service or scope), if any
+}
+
+// matches a full 64 char container id, which is what every runtime we know of uses
+var containerIDRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// prefixes the different runtimes put on the id, of the systemd scope under the
+// systemd cgroup driver, eg: `docker-<id>.scope`, and crio under cgroupfs too
+var scopeRuntimes = []struct {
+  prefix  string
+  runtime string
+}{
+  {"docker-", "docker"},
+  {"cri-containerd-", "containerd"},
+  {"crio-", "crio"},
+  {"libpod-", "podman"},
+}
+
+// NewMountOwner resolves a cgroup path, like the ones found in `/proc/<pid>/cgroup`,
+// into a MountOwner. Both the systemd cgroup driver layout
+// (`/system.slice/docker-<id>.scope`) and the cgroupfs layout (`/docker/<id>`,
+// `/kubepods/burstable/pod<uid>/<id>`) are understood. kubepods is only the
+// slice pods go in, so a bare id under it has no runtime.
+func NewMountOwner(cgroupPath string) MountOwner {
+  owner := MountOwner{CgroupPath: cgroupPath}
+
+  elements := strings.Split(strings.Trim(cgroupPath, "/"), "/")
+  for idx, element := range elements {
+    if strings.HasSuffix(element, ".service") || strings.HasSuffix(element, ".scope") {
+      owner.SystemdUnit = element
+    }
+
+    // the id is prefixed with the runtime, in a scope under the systemd
+    // driver, eg: `crio-<id>.scope`, or as is for crio under cgroupfs
+    var prefixed bool
+    for _, sr := range scopeRuntimes {
+      id := strings.TrimSuffix(strings.TrimPrefix(element, sr.prefix), ".scope")
+      if strings.HasPrefix(element, sr.prefix) && containerIDRegexp.MatchString(id) {
+        owner.ContainerID = id
+        owner.ContainerRuntime = sr.runtime
+        prefixed = true
+      }
+    }
+    if prefixed || strings.HasSuffix(element, ".scope") {
+      continue
+    }
+
+    // cgroupfs driver: the id is the whole element, and the runtime is the
+    // top level of the hierarchy when that's named after one
+    if containerIDRegexp.MatchString(element) && idx > 0 {
+      owner.ContainerID = element
+      owner.ContainerRuntime = ""
+      switch elements[0] {
+      case "docker", "lxc":
+        owner.ContainerRuntime = elements[0]
+      }
+    }
+  }
+
+  return owner
+}
+
+// ParseCgroupPath picks the cgroup path out of the content of a `/proc/<pid>/cgroup`
+// file. On cgroup v2 this is the `0::` line, on v1 or hybrid hierarchies where the
+// unified line is just `/` the `name=systemd` line is used instead, and failing
+// that the first line.
+// Returns an empty string if no path could be found.
+func ParseCgroupPath(content string) string {
+  var unified, systemd, first string
+
+  for _, line := range strings.Split(content, "\n") {
+    // each line is `hierarchy-id:controller-list:cgroup-path`, the path
+    // itself may contain colons so we only split off the first two fields
+    fields := strings.SplitN(strings.TrimSpace(line), ":", 3)
+    if len(fields) != 3 {
+      continue
+    }
+
+    switch {
+    case fields[0] == "0" && fields[1] == "":
+      unified = fields[2]
+    case fields[1] == "name=systemd":
+      systemd = fields[2]
+    case first == "":
+      first = fields[2]
+    }
+  }
+
+  if unified != "" && unified != "/" {
+    return unified
+  }
+  if systemd != "" {
+    return systemd
+  }
+  if first != "" {
+    return first
+  }
+
+  return unified
+}
+
+// NamespaceMountstats is the Mountstats of a single mount namespace, along with
+// the processes that live in it and who owns it.
+type NamespaceMountstats struct {
+  Namespace  string      // the namespace identifier, eg: `mnt:[4026531841]`
+  PIDs       []int       // every pid found in this namespace, ascending
+  Owner      MountOwner  // owner resolved from the lowest pid in the namespace
+  Mountstats *Mountstats // the mounts seen from inside this namespace, nil if Err is set
+  Err        error       // why the mountstats of this namespace couldn't be read or parsed
+}
+
+// NewNamespaceMountstats walks every process under `/proc`, groups them by mount
+// namespace, and reads the mountstats of each namespace once. Every device in
+// the resulting Mountstats is labelled (see the Label* constants) with the
+// namespace and its owner so that NFS usage can be attributed to a container
+// or systemd unit.
+// The owner of a namespace is resolved from the cgroup of the lowest pid in it,
+// which is its init process for containers and pid 1 for the host.
+// Processes that exit during the walk, or that we aren't allowed to inspect, are
+// skipped. The mountstats of a namespace is read through the next pid in it
+// when one of them can't be read, and a namespace whose processes have all
+// exited is left out. A namespace whose mountstats can't be read or parsed for
+// any other reason is returned with Err set, so one bad namespace doesn't cost
+// the rest. Returns an error only if `/proc` itself can't be listed.
+func NewNamespaceMountstats() ([]*NamespaceMountstats, error) {
+  pids, err := procfs.ListPIDs()
+  if err != nil {
+    return nil, err
+  }
+
+  var namespaces []*NamespaceMountstats
+  byNamespace := make(map[string]*NamespaceMountstats)
+  for _, pid := range pids {
+    ns, err := procfs.ReadMountNamespace(pid)
+    if err != nil {
+      continue
+    }
+
+    // pids are sorted, so the first one we see is the lowest
+    if nsm, ok := byNamespace[ns]; ok {
+      nsm.PIDs = append(nsm.PIDs, pid)
+      continue
+    }
+    cgroup, err := procfs.ReadCgroup(pid)
+    if err != nil {
+      continue
+    }
+    nsm := &NamespaceMountstats{
+      Namespace: ns,
+      PIDs: []int{pid},
+      Owner: NewMountOwner(ParseCgroupPath(string(cgroup))),
+    }
+    byNamespace[ns] = nsm
+    namespaces = append(namespaces, nsm)
+  }
+
+  var read []*NamespaceMountstats
+  for _, nsm := range namespaces {
+    content, retries, err := readNamespaceMountstats(nsm)
+    if processExited(err) {
+      continue
+    }
+    if err != nil {
+      nsm.Err = fmt.Errorf("couldn't read mountstats of namespace %s: %w", nsm.Namespace, err)
+      read = append(read, nsm)
+      continue
+    }
+
+    mounts, err := NewMountstatsFromString(string(content))
+    if err != nil {
+      nsm.Err = fmt.Errorf("couldn't parse mountstats of namespace %s: %w", nsm.Namespace, err)
+      read = append(read, nsm)
+      continue
+    }
+    mounts.ReadRetries = retries
+    mounts.setLabels(nsm)
+    nsm.Mountstats = mounts
+    read = append(read, nsm)
+  }
+
+  return read, nil
+}
+
+// readNamespaceMountstats reads the mountstats of `nsm` through the first of
+// its pids that can be read, as any of them may have exited since the walk.
+// Returns the first error that isn't from an exited process, or an exited one
+// if that's all there was.
+func readNamespaceMountstats(nsm *NamespaceMountstats) ([]byte, int, error) {
+  var err error
+  for _, pid := range nsm.PIDs {
+    content, retries, readErr := procfs.ReadPIDMountstatsConsistent(pid, procfs.DefaultReadRetries)
+    if readErr == nil {
+      return content, retries, nil
+    }
+    if err == nil || processExited(err) {
+      err = readErr
+    }
+  }
+
+  return nil, 0, err
+}
+
+// processExited reports whether `err` comes from reading the procfs files of a
+// process that has exited.
+func processExited(err error) bool {
+  return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ESRCH)
+}
+
+// setLabels attaches the namespace and owner of `nsm` as labels to every device.
+func (m *Mountstats) setLabels(nsm *NamespaceMountstats) {
+  for idx := range m.Devices {
+    dev := &m.Devices[idx]
+    if dev.Labels == nil {
+      dev.Labels = make(map[string]string)
+    }
+
+    dev.Labels[LabelMountNamespace] = nsm.Namespace
+    // only set the owner labels we actually resolved, so consumers can
+    // tell "not in a container" apart from an empty id
+    if nsm.Owner.CgroupPath != "" {
+      dev.Labels[LabelCgroup] = nsm.Owner.CgroupPath
+    }
+    if nsm.Owner.ContainerID != "" {
+      dev.Labels[LabelContainerID] = nsm.Owner.ContainerID
+    }
+    if nsm.Owner.ContainerRuntime != "" {
+      dev.Labels[LabelContainerRuntime] = nsm.Owner.ContainerRuntime
+    }
+    if nsm.Owner.SystemdUnit != "" {
+      dev.Labels[LabelSystemdUnit] = nsm.Owner.SystemdUnit
+    }
+  }
+}
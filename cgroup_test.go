@@ -0,0 +1,95 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMountOwner(t *testing.T) {
+  // systemd cgroup driver 
+  owner := nfsmountstats.NewMountOwner("/system.slice/docker-3f4e8a1c9b2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f.scope")
+  assert.Equal(t, "3f4e8a1c9b2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f", owner.ContainerID)
+  assert.Equal(t, "docker", owner.ContainerRuntime)
+  assert.Equal(t, "docker-3f4e8a1c9b2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f.scope", owner.SystemdUnit)
+
+  owner = nfsmountstats.NewMountOwner("/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod6b1f0c3e.slice/cri-containerd-a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90.scope")
+  assert.Equal(t, "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90", owner.ContainerID)
+  assert.Equal(t, "containerd", owner.ContainerRuntime)
+
+  // cgroupfs driver 
+  owner = nfsmountstats.NewMountOwner("/docker/3f4e8a1c9b2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f")
+  assert.Equal(t, "3f4e8a1c9b2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f", owner.ContainerID)
+  assert.Equal(t, "docker", owner.ContainerRuntime)
+  assert.Equal(t, "", owner.SystemdUnit)
+
+  // kubepods is a slice, not a runtime, a bare id under it has none 
+  owner = nfsmountstats.NewMountOwner("/kubepods/burstable/pod6b1f0c3e/a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90")
+  assert.Equal(t, "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90", owner.ContainerID)
+  assert.Equal(t, "", owner.ContainerRuntime)
+
+  // crio prefixes the id under cgroupfs as well 
+  owner = nfsmountstats.NewMountOwner("/kubepods/besteffort/pod6b1f0c3e/crio-a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90")
+  assert.Equal(t, "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90", owner.ContainerID)
+  assert.Equal(t, "crio", owner.ContainerRuntime)
+
+  // plain systemd service, no container 
+  owner = nfsmountstats.NewMountOwner("/system.slice/nfs-backup.service")
+  assert.Equal(t, "", owner.ContainerID)
+  assert.Equal(t, "nfs-backup.service", owner.SystemdUnit)
+}
+
+func TestParseCgroupPath(t *testing.T) {
+  assert.Equal(t, "/init.scope", nfsmountstats.ParseCgroupPath("0::/init.scope\n"))
+
+  // hybrid hierarchy where the unified line is just the root 
+  hybrid := "12:pids:/kubepods/pod1/abc\n1:name=systemd:/kubepods/pod1/def\n0::/\n"
+  assert.Equal(t, "/kubepods/pod1/def", nfsmountstats.ParseCgroupPath(hybrid))
+  assert.Equal(t, "", nfsmountstats.ParseCgroupPath(""))
+}
+
+func TestNewNamespaceMountstats(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  namespaces, err := nfsmountstats.NewNamespaceMountstats()
+  if err != nil {
+    t.Fatalf("error creating namespace mountstats: %v", err)
+  }
+
+  // testdata has the host namespace (pid 1, 812), a docker container (4240,
+  // 4242, 4243), a kubernetes pod (5120) and a backup job (7001). 4240 and
+  // 6001, alone in its namespace, exited before their mountstats could be read 
+  assert.Equal(t, 4, len(namespaces))
+
+  host := namespaces[0]
+  assert.Equal(t, "mnt:[4026531841]", host.Namespace)
+  assert.Equal(t, []int{1, 812}, host.PIDs)
+  assert.Equal(t, "init.scope", host.Owner.SystemdUnit)
+  hostMounts := host.Mountstats.GetNFSMountMap()
+  assert.Equal(t, 1, len(hostMounts))
+  _, ok := hostMounts["/mnt/nfs1/docs"].Labels[nfsmountstats.LabelContainerID]
+  assert.False(t, ok)
+
+  docker := namespaces[1]
+  assert.Equal(t, []int{4240, 4242, 4243}, docker.PIDs)
+  webmail := docker.Mountstats.GetNFSMountMap()["/webmail0"]
+  assert.Equal(t, "3f4e8a1c9b2d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f", webmail.Labels[nfsmountstats.LabelContainerID])
+  assert.Equal(t, "docker", webmail.Labels[nfsmountstats.LabelContainerRuntime])
+  assert.Equal(t, "mnt:[4026532301]", webmail.Labels[nfsmountstats.LabelMountNamespace])
+
+  pod := namespaces[2]
+  mailhome := pod.Mountstats.GetNFSMountMap()["/mailhome6"]
+  assert.Equal(t, "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90", mailhome.Labels[nfsmountstats.LabelContainerID])
+  _, ok = mailhome.Labels[nfsmountstats.LabelContainerRuntime]
+  assert.False(t, ok)
+
+  // the backup job's mountstats is always cut off, which doesn't cost the rest
+  backup := namespaces[3]
+  assert.Equal(t, "backup.service", backup.Owner.SystemdUnit)
+  assert.Nil(t, backup.Mountstats)
+  assert.ErrorIs(t, backup.Err, nfsmountstats.ErrTornRead)
+  for _, nsm := range namespaces[:3] {
+    assert.NoError(t, nsm.Err)
+  }
+}
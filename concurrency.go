@@ -0,0 +1,117 @@
+package nfsmountstats
+
+import (
+	"time"
+)
+
+// ConcurrencyLimit is what keeps a mount from having more requests in flight.
+type ConcurrencyLimit string
+
+const (
+  ConcurrencyIdle       ConcurrencyLimit = "idle"       // less than one request outstanding on average
+  ConcurrencyHeadroom   ConcurrencyLimit = "headroom"   // requests hardly wait on the client, more concurrency won't help
+  ConcurrencySlots      ConcurrencyLimit = "slots"      // requests wait for an rpc slot, a bigger slot table or nconnect would help
+  ConcurrencyConnection ConcurrencyLimit = "connection" // requests wait to be sent while slots are free, nconnect would help
+)
+
+// thresholds for Limit, requests waiting on the client for at least this share
+// of their time, or this many waiting for a slot on average
+const (
+  concurrencyQueueShare = 0.1
+  concurrencyBacklog    = 1.0
+)
+
+// Concurrency estimates the average number of requests a mount has
+// outstanding from Little's law: the total time requests spent outstanding
+// divided by the wall clock time is how many there were at once on average.
+// The transport fields come from the xprt: line, which is shared by every
+// mount on the transport, so the slot use is of all of them together.
+// PeakSlotShare compares the requests in flight, which hold a slot, to the
+// most slots ever in use rather than to the size of the slot table, so it's
+// how close the average gets to the peak. It isn't bounded by 1: InFlight is
+// estimated from the per-op round trip times, which don't line up exactly with
+// when a slot is held, and with nconnect the requests are spread over more
+// transports than the one whose peak is known.
+type Concurrency struct {
+  SampleTime  time.Duration // the time the concurrency is averaged over
+  Outstanding float64       // requests from being queued to being done, CumTotalReqTime / SampleTime
+  Queued      float64       // requests waiting on the client to be sent, CumQueueTime / SampleTime
+  InFlight    float64       // requests waiting for the reply, CumRespTime / SampleTime
+  Ops         []OpConcurrency
+
+  Nconnect      uint64  // connections to the server, 1 unless mounted with nconnect
+  HasSlotStats  bool    // the transport reports MaxRPCSlots, statvers 1.1+
+  MaxRPCSlots   uint64  // high water mark of slots in use since the mount was made, not the slot table size
+  PeakSlotShare float64 // InFlight / MaxRPCSlots, see below
+  AvgBacklog    float64 // requests waiting for a slot, see TransportHealth
+
+  Limit ConcurrencyLimit
+}
+
+// OpConcurrency is the average number of requests of a single op outstanding.
+type OpConcurrency struct {
+  Op          string
+  Outstanding float64
+  Share       float64 // Outstanding as a ratio of the mount's, between 0 and 1
+}
+
+// Concurrency estimates the concurrency since the mount was made, averaged
+// over its Age.
+func (i *NFSInfo) Concurrency() Concurrency {
+  return newConcurrency(i, time.Duration(i.Age)*time.Second, true)
+}
+
+// Concurrency estimates the concurrency over the Interval of the delta.
+func (d *MountDeviceDelta) Concurrency() Concurrency {
+  return newConcurrency(&d.NFSInfo, d.Interval, d.sinceMount())
+}
+
+// newConcurrency estimates the concurrency of the counters in `info`, which
+// are either cumulative or a delta, over `sample`.
+func newConcurrency(info *NFSInfo, sample time.Duration, sinceMount bool) Concurrency {
+  c := Concurrency{SampleTime: sample, Nconnect: 1}
+  if nconnect, ok := info.Options.Uint("nconnect"); ok && nconnect > 0 {
+    c.Nconnect = nconnect
+  }
+
+  millis := float64(sample.Milliseconds())
+  var total RPCOpStat
+  for _, s := range info.RPCOpStats {
+    total = total.add(s)
+  }
+  c.Outstanding = perSecond(total.CumTotalReqTime, millis)
+  c.Queued = perSecond(total.CumQueueTime, millis)
+  c.InFlight = perSecond(total.CumRespTime, millis)
+  for _, op := range info.OpNames() {
+    s := info.RPCOpStats[op]
+    if s.Operations == 0 {
+      continue
+    }
+    c.Ops = append(c.Ops, OpConcurrency{
+      Op: op,
+      Outstanding: perSecond(s.CumTotalReqTime, millis),
+      Share: ratio(s.CumTotalReqTime, total.CumTotalReqTime),
+    })
+  }
+
+  health := newTransportHealth(info.Transport, sample, sinceMount)
+  c.HasSlotStats, c.MaxRPCSlots = health.HasSlotStats, health.MaxRPCSlots
+  c.AvgBacklog = health.AvgBacklog
+  if c.HasSlotStats {
+    c.PeakSlotShare = c.InFlight / float64(c.MaxRPCSlots)
+  }
+
+  queueShare := ratio(total.CumQueueTime, total.CumTotalReqTime)
+  switch {
+  case c.Outstanding < 1:
+    c.Limit = ConcurrencyIdle
+  case c.AvgBacklog >= concurrencyBacklog:
+    c.Limit = ConcurrencySlots
+  case queueShare >= concurrencyQueueShare:
+    c.Limit = ConcurrencyConnection
+  default:
+    c.Limit = ConcurrencyHeadroom
+  }
+
+  return c
+}
@@ -0,0 +1,69 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencySinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  c := findDevice(t, mounts, "/mailhome6").NFSInfo.Concurrency()
+  assert.Equal(t, 2919118*time.Second, c.SampleTime)
+  assert.InDelta(t, 95243366.0/2919118000.0, c.Outstanding, 0.000001)
+  assert.InDelta(t, 82570301.0/2919118000.0, c.InFlight, 0.000001)
+  assert.Equal(t, uint64(1), c.Nconnect)
+  assert.True(t, c.HasSlotStats)
+  assert.Equal(t, uint64(1417), c.MaxRPCSlots)
+  // requests queued for a slot don't hold one
+  assert.InDelta(t, c.InFlight/1417, c.PeakSlotShare, 0.000001)
+  assert.Equal(t, nfsmountstats.ConcurrencyIdle, c.Limit)
+
+  assert.Len(t, c.Ops, 18)
+  var share float64
+  for _, op := range c.Ops {
+    share += op.Share
+  }
+  assert.InDelta(t, 1.0, share, 0.000001)
+}
+
+// concurrencyDelta gives /mailhome6 `queue` and `total` ms of READ time and
+// `backlog` requests waiting for a slot for each of 100 sends over 10s.
+func concurrencyDelta(t *testing.T, queue, total, backlog uint64) *nfsmountstats.MountDeviceDelta {
+  t.Helper()
+  return loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    dev.NFSInfo.Options["nconnect"] = "4"
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations += 100
+    read.CumQueueTime += queue
+    read.CumRespTime += total - queue
+    read.CumTotalReqTime += total
+    dev.NFSInfo.RPCOpStats["READ"] = read
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 100
+    xprt.BacklogUtil += 100 * backlog
+  }).GetMountMap()["/mailhome6"]
+}
+
+func TestConcurrencyDelta(t *testing.T) {
+  // 40s of requests over 10s, 4 at a time, hardly queued
+  c := concurrencyDelta(t, 1000, 40000, 0).Concurrency()
+  assert.Equal(t, 10*time.Second, c.SampleTime)
+  assert.InDelta(t, 4.0, c.Outstanding, 0.000001)
+  assert.InDelta(t, 0.1, c.Queued, 0.000001)
+  assert.Equal(t, uint64(4), c.Nconnect)
+  assert.Equal(t, []nfsmountstats.OpConcurrency{{Op: "READ", Outstanding: 4, Share: 1}}, c.Ops)
+  assert.Equal(t, nfsmountstats.ConcurrencyHeadroom, c.Limit)
+
+  c = concurrencyDelta(t, 10000, 40000, 0).Concurrency()
+  assert.Equal(t, nfsmountstats.ConcurrencyConnection, c.Limit)
+
+  c = concurrencyDelta(t, 10000, 40000, 3).Concurrency()
+  assert.InDelta(t, 3.0, c.AvgBacklog, 0.000001)
+  assert.Equal(t, nfsmountstats.ConcurrencySlots, c.Limit)
+}
@@ -0,0 +1,392 @@
+package nfsmountstats
+
+import (
+	"time"
+)
+
+// reasons recorded in MountDeviceDelta.ResetReasons
+const (
+  ResetRemount   = "remount"   // NFSInfo.Age went backwards, the mount was replaced
+  ResetEvents    = "events"    // an events: counter went backwards
+  ResetBytes     = "bytes"     // a bytes: counter went backwards
+  ResetTransport = "transport" // the xprt: counters went backwards or the protocol changed
+  ResetPerOp     = "per-op"    // a per-op counter went backwards
+)
+
+// MountstatsDelta is the difference between two Mountstats snapshots, holding
+// one MountDeviceDelta for every NFS device in the newer snapshot.
+type MountstatsDelta struct {
+  Devices  []MountDeviceDelta
+  Interval time.Duration // time between the two snapshots, 0 if they weren't timestamped
+}
+
+// MountDeviceDelta holds how much the counters of a single NFS mount changed
+// between two snapshots. The counters in NFSInfo are the change, not the
+// cumulative values, so everything that works on an NFSInfo works on a delta.
+// Opts, Options and Other are taken from the newer snapshot as is, and Age is
+// the number of seconds between the two snapshots as the kernel saw them.
+//
+// Whenever a counter goes backwards the counters it belongs to can't be
+// differenced, so the current values are used for that section instead (they
+// count from whenever the reset happened, which is the best we can do), and
+// the section is recorded in ResetReasons. When the mount itself was replaced
+// (Age went backwards) every counter is since the new mount.
+type MountDeviceDelta struct {
+  Device       string
+  Mountpoint   string
+  MountType    string
+  NFSInfo      NFSInfo
+  Interval     time.Duration // the time the counters cover, see Delta
+  New          bool          // the device wasn't in the older snapshot, counters are since mount
+  Reset        bool          // some counters were reset, see ResetReasons
+  ResetReasons []string      // which sections were reset, the Reset* constants
+}
+
+// GetMountMap returns a map of the device deltas keyed on mountpoint.
+func (d *MountstatsDelta) GetMountMap() map[string]*MountDeviceDelta {
+  deltamap := make(map[string]*MountDeviceDelta)
+  for idx := range d.Devices {
+    deltamap[d.Devices[idx].Mountpoint] = &d.Devices[idx]
+  }
+
+  return deltamap
+}
+
+// Delta computes the change in every NFS counter from `prev` to `m`. Devices are
+// matched on both their Device and Mountpoint, if the same pair is mounted more
+// than once they're matched in order of appearance. Devices that only exist in
+// `prev` are unmounted and left out, devices only in `m` are marked New.
+// A nil `prev` makes every device New.
+// The Interval is measured between the snapshot Timestamps, using the monotonic 
+// clock when both have it, or else the kernel Uptime. Each device gets the same 
+// Interval, except for New or remounted devices whose counters cover their whole 
+// Age, and devices in untimestamped snapshots which fall back to the Age delta.
+func (m *Mountstats) Delta(prev *Mountstats) *MountstatsDelta {
+  // index the previous snapshot on device+mountpoint, keeping a list in
+  // case of stacked mounts of the same export on the same path
+  previous := make(map[string][]*MountDevice)
+  if prev != nil {
+    for _, dev := range prev.GetNFSDevices() {
+      key := dev.Device + " " + dev.Mountpoint
+      previous[key] = append(previous[key], dev)
+    }
+  }
+
+  delta := MountstatsDelta{Interval: snapshotInterval(prev, m)}
+  for _, dev := range m.GetNFSDevices() {
+    key := dev.Device + " " + dev.Mountpoint
+    var prevDev *MountDevice
+    if len(previous[key]) > 0 {
+      prevDev = previous[key][0]
+      previous[key] = previous[key][1:]
+    }
+
+    devDelta := newMountDeviceDelta(dev, prevDev)
+    devDelta.Interval = delta.Interval
+    if devDelta.Interval <= 0 || devDelta.sinceMount() {
+      devDelta.Interval = time.Duration(devDelta.NFSInfo.Age) * time.Second
+    }
+    delta.Devices = append(delta.Devices, *devDelta)
+  }
+
+  return &delta
+}
+
+// snapshotInterval returns the time between two snapshots, preferring the 
+// Timestamps (which compare on the monotonic clock when both were taken by 
+// this process) and falling back to the kernel uptime. 
+// Returns 0 if the interval can't be determined or isn't positive.
+func snapshotInterval(prev *Mountstats, cur *Mountstats) time.Duration {
+  if prev == nil {
+    return 0
+  }
+
+  var interval time.Duration
+  switch {
+  case !prev.Timestamp.IsZero() && !cur.Timestamp.IsZero():
+    interval = cur.Timestamp.Sub(prev.Timestamp)
+  case prev.Uptime > 0 && cur.Uptime > 0:
+    interval = cur.Uptime - prev.Uptime
+  }
+  if interval < 0 {
+    return 0
+  }
+
+  return interval
+}
+
+// newMountDeviceDelta computes the delta of a single device from `prev` to
+// `cur`, `prev` may be nil if the device is new.
+func newMountDeviceDelta(cur *MountDevice, prev *MountDevice) *MountDeviceDelta {
+  delta := MountDeviceDelta{
+    Device: cur.Device,
+    Mountpoint: cur.Mountpoint,
+    MountType: cur.MountType,
+  }
+
+  info := &delta.NFSInfo
+  info.Opts = cur.NFSInfo.Opts
+  info.Options = cur.NFSInfo.Options
+  info.Other = cur.NFSInfo.Other
+  info.RPCOps = cur.NFSInfo.RPCOps
+  info.HasErrStats = cur.NFSInfo.HasErrStats
+
+  // no previous device or a new mount in its place, everything is since mount
+  if prev == nil || cur.NFSInfo.Age < prev.NFSInfo.Age {
+    delta.New = prev == nil
+    if prev != nil {
+      delta.addReset(ResetRemount)
+    }
+    info.Age = cur.NFSInfo.Age
+    info.Events = cur.NFSInfo.Events
+    info.Bytes = cur.NFSInfo.Bytes
+    info.Transport = cur.NFSInfo.Transport
+    info.RPCOpStats = make(map[string]RPCOpStat, len(cur.NFSInfo.RPCOpStats))
+    for op, stat := range cur.NFSInfo.RPCOpStats {
+      info.RPCOpStats[op] = stat
+    }
+    return &delta
+  }
+
+  info.Age = cur.NFSInfo.Age - prev.NFSInfo.Age
+
+  var ok bool
+  info.Events, ok = cur.NFSInfo.Events.delta(prev.NFSInfo.Events)
+  if !ok {
+    delta.addReset(ResetEvents)
+  }
+  info.Bytes, ok = cur.NFSInfo.Bytes.delta(prev.NFSInfo.Bytes)
+  if !ok {
+    delta.addReset(ResetBytes)
+  }
+  info.Transport, ok = deltaTransportCounters(cur.NFSInfo.Transport, prev.NFSInfo.Transport)
+  if !ok {
+    delta.addReset(ResetTransport)
+  }
+
+  info.RPCOpStats = make(map[string]RPCOpStat, len(cur.NFSInfo.RPCOpStats))
+  perOpReset := false
+  for op, stat := range cur.NFSInfo.RPCOpStats {
+    // an op that wasn't there before just counts from zero
+    prevStat := prev.NFSInfo.RPCOpStats[op]
+    info.RPCOpStats[op], ok = stat.delta(prevStat)
+    if !ok {
+      perOpReset = true
+    }
+  }
+  if perOpReset {
+    delta.addReset(ResetPerOp)
+  }
+
+  return &delta
+}
+
+// addReset flags the delta as reset and records why.
+func (d *MountDeviceDelta) addReset(reason string) {
+  d.Reset = true
+  d.ResetReasons = append(d.ResetReasons, reason)
+}
+
+// hasReset reports whether `reason` is one of the recorded ResetReasons.
+func (d *MountDeviceDelta) hasReset(reason string) bool {
+  for _, r := range d.ResetReasons {
+    if r == reason {
+      return true
+    }
+  }
+
+  return false
+}
+
+// sinceMount reports whether the counters of the delta are since the mount was
+// made rather than over the Interval, because it's New or was remounted.
+func (d *MountDeviceDelta) sinceMount() bool {
+  return d.New || d.hasReset(ResetRemount)
+}
+
+// counterDiff subtracts pairs of counters while remembering if any of them
+// went backwards, so that a whole section can be treated as reset at once.
+type counterDiff struct {
+  reset bool
+}
+
+// sub returns cur - prev, or 0 and flags a reset if the counter went backwards.
+func (c *counterDiff) sub(cur, prev uint64) uint64 {
+  if cur < prev {
+    c.reset = true
+    return 0
+  }
+
+  return cur - prev
+}
+
+// delta returns the change in every event counter since `prev`. If any of the
+// counters went backwards the current counters are returned along with false.
+func (e NFSEventCounters) delta(prev NFSEventCounters) (NFSEventCounters, bool) {
+  c := counterDiff{}
+  d := NFSEventCounters{
+    InodeRevalidates: c.sub(e.InodeRevalidates, prev.InodeRevalidates),
+    DentryRevalidates: c.sub(e.DentryRevalidates, prev.DentryRevalidates),
+    DataInvalidates: c.sub(e.DataInvalidates, prev.DataInvalidates),
+    AttrInvalidates: c.sub(e.AttrInvalidates, prev.AttrInvalidates),
+    VfsOpen: c.sub(e.VfsOpen, prev.VfsOpen),
+    VfsLookup: c.sub(e.VfsLookup, prev.VfsLookup),
+    VfsPermission: c.sub(e.VfsPermission, prev.VfsPermission),
+    VfsUpdatePage: c.sub(e.VfsUpdatePage, prev.VfsUpdatePage),
+    VfsReadPage: c.sub(e.VfsReadPage, prev.VfsReadPage),
+    VfsReadPages: c.sub(e.VfsReadPages, prev.VfsReadPages),
+    VfsWritePage: c.sub(e.VfsWritePage, prev.VfsWritePage),
+    VfsWritePages: c.sub(e.VfsWritePages, prev.VfsWritePages),
+    VfsReaddir: c.sub(e.VfsReaddir, prev.VfsReaddir),
+    VfsSetAttr: c.sub(e.VfsSetAttr, prev.VfsSetAttr),
+    VfsFlush: c.sub(e.VfsFlush, prev.VfsFlush),
+    VfsFsync: c.sub(e.VfsFsync, prev.VfsFsync),
+    VfsLock: c.sub(e.VfsLock, prev.VfsLock),
+    VfsRelease: c.sub(e.VfsRelease, prev.VfsRelease),
+    CongestionWait: c.sub(e.CongestionWait, prev.CongestionWait),
+    SetAttrTrunc: c.sub(e.SetAttrTrunc, prev.SetAttrTrunc),
+    ExtendWrite: c.sub(e.ExtendWrite, prev.ExtendWrite),
+    SillyRenames: c.sub(e.SillyRenames, prev.SillyRenames),
+    ShortReads: c.sub(e.ShortReads, prev.ShortReads),
+    ShortWrites: c.sub(e.ShortWrites, prev.ShortWrites),
+    Delay: c.sub(e.Delay, prev.Delay),
+    PNFSRead: c.sub(e.PNFSRead, prev.PNFSRead),
+    PNFSWrite: c.sub(e.PNFSWrite, prev.PNFSWrite),
+  }
+  if c.reset {
+    return e, false
+  }
+
+  return d, true
+}
+
+// delta returns the change in every byte counter since `prev`. If any of the
+// counters went backwards the current counters are returned along with false.
+func (b NFSByteCounters) delta(prev NFSByteCounters) (NFSByteCounters, bool) {
+  c := counterDiff{}
+  d := NFSByteCounters{
+    NormalReadBytes: c.sub(b.NormalReadBytes, prev.NormalReadBytes),
+    NormalWriteBytes: c.sub(b.NormalWriteBytes, prev.NormalWriteBytes),
+    DirectReadBytes: c.sub(b.DirectReadBytes, prev.DirectReadBytes),
+    DirectWriteBytes: c.sub(b.DirectWriteBytes, prev.DirectWriteBytes),
+    ServerReadBytes: c.sub(b.ServerReadBytes, prev.ServerReadBytes),
+    ServerWriteBytes: c.sub(b.ServerWriteBytes, prev.ServerWriteBytes),
+    ReadPages: c.sub(b.ReadPages, prev.ReadPages),
+    WritePages: c.sub(b.WritePages, prev.WritePages),
+  }
+  if c.reset {
+    return b, false
+  }
+
+  return d, true
+}
+
+// delta returns the change in every counter of a single op since `prev`. If
+// any of the counters went backwards the current counters are returned along
+// with false.
+func (s RPCOpStat) delta(prev RPCOpStat) (RPCOpStat, bool) {
+  c := counterDiff{}
+  d := RPCOpStat{
+    Operations: c.sub(s.Operations, prev.Operations),
+    Transmissions: c.sub(s.Transmissions, prev.Transmissions),
+    MajorTimeouts: c.sub(s.MajorTimeouts, prev.MajorTimeouts),
+    BytesSent: c.sub(s.BytesSent, prev.BytesSent),
+    BytesReceived: c.sub(s.BytesReceived, prev.BytesReceived),
+    CumQueueTime: c.sub(s.CumQueueTime, prev.CumQueueTime),
+    CumRespTime: c.sub(s.CumRespTime, prev.CumRespTime),
+    CumTotalReqTime: c.sub(s.CumTotalReqTime, prev.CumTotalReqTime),
+    ErrStats: c.sub(s.ErrStats, prev.ErrStats),
+  }
+  if c.reset {
+    return s, false
+  }
+
+  return d, true
+}
+
+// deltaTransportCounters returns the change in the transport counters since
+// `prev`. Fields that aren't counters (the port, connect time, idle time and the
+// max slot high water mark) are taken from `cur` as is. If the protocol changed
+// or any counter went backwards `cur` is returned along with false.
+// The concrete type depends on the protocol, like ParseNFSTransportCounters.
+func deltaTransportCounters(cur NFSTransportCounters, prev NFSTransportCounters) (NFSTransportCounters, bool) {
+  c := counterDiff{}
+
+  switch cur := cur.(type) {
+  case *NFSTransportCountersUDP:
+    prev, ok := prev.(*NFSTransportCountersUDP)
+    if !ok {
+      return cur, false
+    }
+    d := &NFSTransportCountersUDP{
+      Port: cur.Port,
+      BindCount: c.sub(cur.BindCount, prev.BindCount),
+      RpcSends: c.sub(cur.RpcSends, prev.RpcSends),
+      RpcReceives: c.sub(cur.RpcReceives, prev.RpcReceives),
+      BadXids: c.sub(cur.BadXids, prev.BadXids),
+      InflightSends: c.sub(cur.InflightSends, prev.InflightSends),
+      BacklogUtil: c.sub(cur.BacklogUtil, prev.BacklogUtil),
+    }
+    if c.reset {
+      return cur, false
+    }
+    return d, true
+  case *NFSTransportCountersTCP:
+    prev, ok := prev.(*NFSTransportCountersTCP)
+    if !ok {
+      return cur, false
+    }
+    d := &NFSTransportCountersTCP{
+      Port: cur.Port,
+      BindCount: c.sub(cur.BindCount, prev.BindCount),
+      ConnectCount: c.sub(cur.ConnectCount, prev.ConnectCount),
+      ConnectTime: cur.ConnectTime,
+      IdleTime: cur.IdleTime,
+      RpcSends: c.sub(cur.RpcSends, prev.RpcSends),
+      RpcReceives: c.sub(cur.RpcReceives, prev.RpcReceives),
+      BadXids: c.sub(cur.BadXids, prev.BadXids),
+      InflightSends: c.sub(cur.InflightSends, prev.InflightSends),
+      BacklogUtil: c.sub(cur.BacklogUtil, prev.BacklogUtil),
+      MaxRPCSlots: cur.MaxRPCSlots,
+      CumSendingQueue: c.sub(cur.CumSendingQueue, prev.CumSendingQueue),
+      CumPendingQueue: c.sub(cur.CumPendingQueue, prev.CumPendingQueue),
+    }
+    if c.reset {
+      return cur, false
+    }
+    return d, true
+  case *NFSTransportCountersRDMA:
+    prev, ok := prev.(*NFSTransportCountersRDMA)
+    if !ok {
+      return cur, false
+    }
+    d := &NFSTransportCountersRDMA{
+      Port: cur.Port,
+      BindCount: c.sub(cur.BindCount, prev.BindCount),
+      ConnectCount: c.sub(cur.ConnectCount, prev.ConnectCount),
+      ConnectTime: cur.ConnectTime,
+      IdleTime: cur.IdleTime,
+      RpcSends: c.sub(cur.RpcSends, prev.RpcSends),
+      RpcReceives: c.sub(cur.RpcReceives, prev.RpcReceives),
+      BadXids: c.sub(cur.BadXids, prev.BadXids),
+      BacklogUtil: c.sub(cur.BacklogUtil, prev.BacklogUtil),
+      ReadChunks: c.sub(cur.ReadChunks, prev.ReadChunks),
+      WriteChunks: c.sub(cur.WriteChunks, prev.WriteChunks),
+      ReplyChunks: c.sub(cur.ReplyChunks, prev.ReplyChunks),
+      TotalRdmaReq: c.sub(cur.TotalRdmaReq, prev.TotalRdmaReq),
+      TotalRdmaRep: c.sub(cur.TotalRdmaRep, prev.TotalRdmaRep),
+      Pullup: c.sub(cur.Pullup, prev.Pullup),
+      Fixup: c.sub(cur.Fixup, prev.Fixup),
+      Hardway: c.sub(cur.Hardway, prev.Hardway),
+      FailedMarshal: c.sub(cur.FailedMarshal, prev.FailedMarshal),
+      BadReply: c.sub(cur.BadReply, prev.BadReply),
+    }
+    if c.reset {
+      return cur, false
+    }
+    return d, true
+  }
+
+  // no transport line at all, nothing to difference
+  return cur, prev == nil
+}
@@ -0,0 +1,197 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadTestMountstats parses the testdata mountstats file into a new Mountstats,
+// every call returns an independent copy that can be modified freely.
+func loadTestMountstats(t *testing.T) *nfsmountstats.Mountstats {
+  t.Helper()
+  procfs.PathPrefix = "testdata"
+  content, err  := procfs.ReadMountstats()
+  if err != nil {
+    t.Fatalf("couldn't read mountstats file: %v", err)
+  }
+
+  mounts, err := nfsmountstats.NewMountstatsFromString(string(content))
+  if err != nil {
+    t.Fatalf("error creating new Mountstats: %v", err)
+  }
+
+  return mounts
+}
+
+// loadTestSnapshots loads two snapshots `interval` apart, and lets `mutate`
+// change the counters of the later one before they're returned.
+func loadTestSnapshots(t *testing.T, interval time.Duration, mutate func(cur *nfsmountstats.Mountstats)) (*nfsmountstats.Mountstats, *nfsmountstats.Mountstats) {
+  t.Helper()
+  prev := loadTestMountstats(t)
+  cur := loadTestMountstats(t)
+
+  prev.Timestamp = time.Now()
+  cur.Timestamp = prev.Timestamp.Add(interval)
+  mutate(cur)
+
+  return prev, cur
+}
+
+// loadTestDelta returns the delta between two snapshots `interval` apart, the
+// later one changed by `mutate`, see loadTestSnapshots.
+func loadTestDelta(t *testing.T, interval time.Duration, mutate func(cur *nfsmountstats.Mountstats)) *nfsmountstats.MountstatsDelta {
+  t.Helper()
+  prev, cur := loadTestSnapshots(t, interval, mutate)
+
+  return cur.Delta(prev)
+}
+
+// findDevice returns a pointer to the device in `m` mounted on `mountpoint`, so
+// that tests can change its counters in place.
+func findDevice(t *testing.T, m *nfsmountstats.Mountstats, mountpoint string) *nfsmountstats.MountDevice {
+  t.Helper()
+  for idx := range m.Devices {
+    if m.Devices[idx].Mountpoint == mountpoint {
+      return &m.Devices[idx]
+    }
+  }
+  t.Fatalf("no device mounted on %v", mountpoint)
+
+  return nil
+}
+
+// removeDevice removes the device mounted on `mountpoint` from `m`, as if it
+// wasn't mounted when `m` was read.
+func removeDevice(t *testing.T, m *nfsmountstats.Mountstats, mountpoint string) {
+  t.Helper()
+  for idx := range m.Devices {
+    if m.Devices[idx].Mountpoint == mountpoint {
+      m.Devices = append(m.Devices[:idx], m.Devices[idx+1:]...)
+      return
+    }
+  }
+  t.Fatalf("no device mounted on %v", mountpoint)
+}
+
+func TestDeltaUnchanged(t *testing.T) {
+  prev := loadTestMountstats(t)
+  cur := loadTestMountstats(t)
+
+  delta := cur.Delta(prev)
+  assert.Equal(t, 10, len(delta.Devices))
+  for _, dev := range delta.Devices {
+    assert.False(t, dev.Reset, dev.Mountpoint)
+    assert.False(t, dev.New, dev.Mountpoint)
+    assert.Equal(t, uint64(0), dev.NFSInfo.Age)
+    assert.Equal(t, nfsmountstats.NFSEventCounters{}, dev.NFSInfo.Events)
+    assert.Equal(t, nfsmountstats.NFSByteCounters{}, dev.NFSInfo.Bytes)
+    assert.Equal(t, nfsmountstats.RPCOpStat{}, dev.NFSInfo.RPCOpStats["READ"])
+  }
+
+  // non counter fields of the transport are carried over, not differenced
+  xprt := delta.GetMountMap()["/mailhome6"].NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+  assert.Equal(t, uint64(840), xprt.Port)
+  assert.Equal(t, uint64(1417), xprt.MaxRPCSlots)
+  assert.Equal(t, uint64(0), xprt.RpcSends)
+}
+
+func TestDeltaCounters(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    dev.NFSInfo.Events.VfsOpen += 40
+    dev.NFSInfo.Bytes.ServerReadBytes += 1048576
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations += 32
+    read.Transmissions += 33
+    read.BytesReceived += 1048576
+    read.CumRespTime += 640
+    dev.NFSInfo.RPCOpStats["READ"] = read
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 33
+    xprt.RpcReceives += 33
+  }).GetMountMap()["/mailhome6"]
+  assert.False(t, delta.Reset)
+  assert.Equal(t, uint64(10), delta.NFSInfo.Age)
+  assert.Equal(t, uint64(40), delta.NFSInfo.Events.VfsOpen)
+  assert.Equal(t, uint64(1048576), delta.NFSInfo.Bytes.ServerReadBytes)
+  assert.Equal(t, uint64(32), delta.NFSInfo.RPCOpStats["READ"].Operations)
+  assert.Equal(t, uint64(33), delta.NFSInfo.RPCOpStats["READ"].Transmissions)
+  assert.Equal(t, uint64(640), delta.NFSInfo.RPCOpStats["READ"].CumRespTime)
+  assert.Equal(t, uint64(0), delta.NFSInfo.RPCOpStats["WRITE"].Operations)
+  assert.Equal(t, uint64(33), delta.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP).RpcSends)
+  assert.Equal(t, "tcp", delta.NFSInfo.Transport.Protocol())
+  assert.True(t, delta.NFSInfo.Options.Has("hard"))
+}
+
+func TestDeltaRemount(t *testing.T) {
+  // a younger mount in the same place is a remount, so all of the counters
+  // should be the ones since mount rather than a huge wrapped around number
+  delta := loadTestDelta(t, 30*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mnt/nfs1/docs")
+    dev.NFSInfo.Age = 30
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations = 5
+    dev.NFSInfo.RPCOpStats["READ"] = read
+  }).GetMountMap()["/mnt/nfs1/docs"]
+  assert.True(t, delta.Reset)
+  assert.Equal(t, []string{nfsmountstats.ResetRemount}, delta.ResetReasons)
+  assert.Equal(t, uint64(30), delta.NFSInfo.Age)
+  assert.Equal(t, uint64(5), delta.NFSInfo.RPCOpStats["READ"].Operations)
+  assert.Equal(t, uint64(13910), delta.NFSInfo.Events.InodeRevalidates)
+}
+
+func TestDeltaCounterReset(t *testing.T) {
+  // a transport reconnect that starts the xprt counters over, while the rest
+  // of the mount keeps counting
+  delta := loadTestDelta(t, 60*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/webmail0")
+    dev.NFSInfo.Age += 60
+    dev.NFSInfo.Transport = &nfsmountstats.NFSTransportCountersTCP{RpcSends: 100, RpcReceives: 99, ConnectCount: 1}
+    getattr := dev.NFSInfo.RPCOpStats["GETATTR"]
+    getattr.Operations += 100
+    dev.NFSInfo.RPCOpStats["GETATTR"] = getattr
+  }).GetMountMap()["/webmail0"]
+  assert.True(t, delta.Reset)
+  assert.Equal(t, []string{nfsmountstats.ResetTransport}, delta.ResetReasons)
+  assert.Equal(t, uint64(100), delta.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP).RpcSends)
+  assert.Equal(t, uint64(100), delta.NFSInfo.RPCOpStats["GETATTR"].Operations)
+
+  // a single per-op counter going backwards
+  delta = loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/webmail0")
+    getattr := dev.NFSInfo.RPCOpStats["GETATTR"]
+    getattr.CumRespTime = 10
+    dev.NFSInfo.RPCOpStats["GETATTR"] = getattr
+  }).GetMountMap()["/webmail0"]
+  assert.Equal(t, []string{nfsmountstats.ResetPerOp}, delta.ResetReasons)
+  assert.Equal(t, uint64(10), delta.NFSInfo.RPCOpStats["GETATTR"].CumRespTime)
+}
+
+func TestDeltaNewAndRemoved(t *testing.T) {
+  cur := loadTestMountstats(t)
+
+  delta := cur.Delta(nil)
+  assert.Equal(t, 10, len(delta.Devices))
+  assert.True(t, delta.Devices[0].New)
+  assert.Equal(t, uint64(258103), delta.Devices[0].NFSInfo.Age)
+
+  // a mount that appeared is new
+  prev := loadTestMountstats(t)
+  removeDevice(t, prev, "/mnt/nfs1/code")
+  deltamap := cur.Delta(prev).GetMountMap()
+  assert.Equal(t, 10, len(deltamap))
+  assert.True(t, deltamap["/mnt/nfs1/code"].New)
+  assert.False(t, deltamap["/mnt/nfs1/docs"].New)
+
+  // a mount that went away is dropped
+  removeDevice(t, cur, "/mnt/nfs1/docs")
+  deltamap = cur.Delta(loadTestMountstats(t)).GetMountMap()
+  assert.Equal(t, 9, len(deltamap))
+  _, ok := deltamap["/mnt/nfs1/docs"]
+  assert.False(t, ok)
+}
@@ -0,0 +1,220 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Policy is a set of rules every NFS mount's configuration is checked against.
+// It can be built in code or loaded from JSON, eg:
+//
+//	{"Rules": [{"ID": "no-v3-udp", "Severity": "warning",
+//	  "When": {"Versions": ["3"]}, "Require": {"Protocols": ["tcp", "rdma"]}}]}
+type Policy struct {
+  Rules []PolicyRule
+}
+
+// PolicyRule is a single rule of a Policy. A rule applies to the mounts on
+// Mountpoints that match When, and those have to match Require. A mount that
+// doesn't is reported as a Finding with the rule's ID and Severity.
+type PolicyRule struct {
+  ID          string
+  Severity    Severity
+  Description string
+  Mountpoints []string // globs of the mountpoints the rule applies to (see path.Match), empty for every mount
+  When        PolicyConditions
+  Require     PolicyConditions
+}
+
+// PolicyConditions is what a mount has to be like to match. Empty fields match
+// any mount, so an empty PolicyConditions matches every mount.
+type PolicyConditions struct {
+  Versions   []string // the NFS version is one of these, eg: `3` or `4.2`, `4` and `4.0` are the same
+  MinVersion string   // the NFS version is this or later, eg: `4.1`
+  Protocols  []string // the transport protocol is one of these, eg: `tcp`
+  Security   []string // the security flavor is one of these, eg: `krb5p`, `sys` when the sec option isn't set
+  Options    []string // options that are all set, either `name` or `name=value`
+  NotOptions []string // options that are all not set, either `name` or `name=value`
+}
+
+// DefaultPolicy returns the rules most sites would want: TCP (or RDMA) rather
+// than UDP, hard mounts, and NFSv4.1 or later for sessions.
+func DefaultPolicy() *Policy {
+  return &Policy{Rules: []PolicyRule{
+    {
+      ID: "no-udp",
+      Severity: SeverityWarning,
+      Description: "UDP retransmits whole requests on any loss and can corrupt data at high rates",
+      Require: PolicyConditions{Protocols: []string{"tcp", "rdma"}},
+    },
+    {
+      ID: "hard-mounts",
+      Severity: SeverityWarning,
+      Description: "soft mounts return EIO when the server is slow, which can lose writes",
+      Require: PolicyConditions{NotOptions: []string{"soft", "softerr"}},
+    },
+    {
+      ID: "nfs-4.1",
+      Severity: SeverityInfo,
+      Description: "NFSv4.1 and later have sessions, which make retransmissions safe",
+      Require: PolicyConditions{MinVersion: "4.1"},
+    },
+  }}
+}
+
+// Check checks every NFS mount against the policy.
+func (p *Policy) Check(m *Mountstats) []Finding {
+  var findings []Finding
+  for _, dev := range m.GetNFSDevices() {
+    findings = append(findings, p.CheckDevice(dev)...)
+  }
+  SortFindings(findings)
+
+  return findings
+}
+
+// CheckDevice checks a single mount against the policy, with a Finding for
+// every rule it breaks.
+func (p *Policy) CheckDevice(d *MountDevice) []Finding {
+  var findings []Finding
+  for _, rule := range p.Rules {
+    if !rule.appliesTo(d) {
+      continue
+    }
+    violations := rule.Require.violations(d)
+    if len(violations) == 0 {
+      continue
+    }
+
+    message := strings.Join(violations, ", ")
+    if rule.Description != "" {
+      message += ": " + rule.Description
+    }
+    findings = append(findings, Finding{
+      ID: rule.ID,
+      Severity: rule.Severity,
+      Device: d.Device,
+      Mountpoint: d.Mountpoint,
+      Message: message,
+    })
+  }
+
+  return findings
+}
+
+// appliesTo reports whether the rule applies to `d`.
+func (r PolicyRule) appliesTo(d *MountDevice) bool {
+  if len(r.Mountpoints) > 0 && !slices.ContainsFunc(r.Mountpoints, func(pattern string) bool {
+    matched, _ := path.Match(pattern, d.Mountpoint)
+    return matched
+  }) {
+    return false
+  }
+
+  return len(r.When.violations(d)) == 0
+}
+
+// violations describes every way `d` doesn't match the conditions.
+func (c PolicyConditions) violations(d *MountDevice) []string {
+  var violations []string
+  options := d.NFSInfo.Options
+
+  version := nfsVersion(d.MountType, options)
+  // versions compare on their numbers, the kernel prints `vers=4.0` for what
+  // a rule calls `4`, and an nfs4 mount without a vers option is just `4`
+  if len(c.Versions) > 0 && !slices.ContainsFunc(c.Versions, func(v string) bool { return compareVersions(version, v) == 0 }) {
+    violations = append(violations, fmt.Sprintf("version %s isn't one of %s", orUnknown(version), strings.Join(c.Versions, ", ")))
+  }
+  if c.MinVersion != "" && compareVersions(version, c.MinVersion) < 0 {
+    violations = append(violations, fmt.Sprintf("version %s is older than %s", orUnknown(version), c.MinVersion))
+  }
+
+  protocol := options.Get("proto")
+  if d.NFSInfo.Transport != nil {
+    protocol = d.NFSInfo.Transport.Protocol()
+  }
+  if len(c.Protocols) > 0 && !slices.Contains(c.Protocols, protocol) {
+    violations = append(violations, fmt.Sprintf("protocol %s isn't one of %s", orUnknown(protocol), strings.Join(c.Protocols, ", ")))
+  }
+
+  security := options.Get("sec")
+  if security == "" {
+    security = "sys"
+  }
+  if len(c.Security) > 0 && !slices.Contains(c.Security, security) {
+    violations = append(violations, fmt.Sprintf("security %s isn't one of %s", security, strings.Join(c.Security, ", ")))
+  }
+
+  for _, option := range c.Options {
+    if !hasOption(options, option) {
+      violations = append(violations, fmt.Sprintf("option %s isn't set", option))
+    }
+  }
+  for _, option := range c.NotOptions {
+    if hasOption(options, option) {
+      violations = append(violations, fmt.Sprintf("option %s is set", option))
+    }
+  }
+
+  return violations
+}
+
+// hasOption reports whether `option` is set, either `name` with any value or
+// `name=value`.
+func hasOption(options NFSMountOptions, option string) bool {
+  name, value, withValue := strings.Cut(option, "=")
+  if !withValue {
+    return options.Has(name)
+  }
+
+  return options.Has(name) && options.Get(name) == value
+}
+
+// compareVersions compares two NFS versions like `4.1` component by
+// component, returning -1, 0 or 1. A version that isn't a number, or is empty,
+// is older than any other.
+func compareVersions(a, b string) int {
+  as, aok := parseVersion(a)
+  bs, bok := parseVersion(b)
+  switch {
+  case !aok && !bok:
+    return 0
+  case !aok:
+    return -1
+  case !bok:
+    return 1
+  }
+
+  return slices.Compare(as, bs)
+}
+
+// parseVersion splits a version into its numbers, a missing minor version is
+// 0 so `4` and `4.0` are the same.
+func parseVersion(version string) ([]int, bool) {
+  if version == "" {
+    return nil, false
+  }
+
+  parts := []int{0, 0}
+  for idx, field := range strings.SplitN(version, ".", 2) {
+    n, err := strconv.Atoi(field)
+    if err != nil {
+      return nil, false
+    }
+    parts[idx] = n
+  }
+
+  return parts, true
+}
+
+// orUnknown returns `s`, or "unknown" if it's empty.
+func orUnknown(s string) string {
+  if s == "" {
+    return "unknown"
+  }
+
+  return s
+}
@@ -0,0 +1,113 @@
+package nfsmountstats_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  findings := nfsmountstats.DefaultPolicy().Check(mounts)
+  assert.Len(t, findings, 7)
+  // the opts say tcp, but the transport is udp
+  assert.Equal(t, "no-udp", findings[0].ID)
+  assert.Equal(t, nfsmountstats.SeverityWarning, findings[0].Severity)
+  assert.Equal(t, "/mailhome5udp", findings[0].Mountpoint)
+  assert.Contains(t, findings[0].Message, "protocol udp isn't one of tcp, rdma")
+
+  // every NFSv3 mount, and none of the NFSv4.2 ones
+  for _, finding := range findings[1:] {
+    assert.Equal(t, "nfs-4.1", finding.ID)
+    assert.Equal(t, nfsmountstats.SeverityInfo, finding.Severity)
+    assert.Contains(t, finding.Message, "version 3 is older than 4.1")
+    assert.NotContains(t, finding.Mountpoint, "/mnt/nfs1")
+  }
+}
+
+func TestPolicyRules(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  policy := &nfsmountstats.Policy{Rules: []nfsmountstats.PolicyRule{
+    {
+      ID: "no-v3-udp",
+      Severity: nfsmountstats.SeverityCritical,
+      When: nfsmountstats.PolicyConditions{Versions: []string{"3"}},
+      Require: nfsmountstats.PolicyConditions{Protocols: []string{"tcp", "rdma"}},
+    },
+    {
+      ID: "secure-docs",
+      Severity: nfsmountstats.SeverityWarning,
+      Mountpoints: []string{"/mnt/nfs1/docs*"},
+      Require: nfsmountstats.PolicyConditions{Security: []string{"krb5p"}, Options: []string{"hard", "vers=4.2"}},
+    },
+    {
+      ID: "local-locks",
+      Severity: nfsmountstats.SeverityInfo,
+      Require: nfsmountstats.PolicyConditions{NotOptions: []string{"local_lock=all"}, MinVersion: "4"},
+    },
+  }}
+
+  findings := policy.Check(mounts)
+  var ids []string
+  for _, finding := range findings {
+    ids = append(ids, finding.ID+" "+finding.Mountpoint)
+  }
+  assert.Equal(t, []string{
+    "no-v3-udp /mailhome5udp",
+    "secure-docs /mnt/nfs1/docs",
+    "secure-docs /mnt/nfs1/docs_work",
+    "local-locks /fakehomestatver1",
+    "local-locks /mailhome5",
+    "local-locks /mailhome5rdma",
+    "local-locks /mailhome5udp",
+    "local-locks /mailhome6",
+    "local-locks /webmail0",
+  }, ids)
+  assert.Equal(t, "security sys isn't one of krb5p", findings[1].Message)
+  assert.Equal(t, "version 3 is older than 4, option local_lock=all is set", findings[3].Message)
+}
+
+func TestPolicyFromJSON(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  var policy nfsmountstats.Policy
+  err := json.Unmarshal([]byte(`{"Rules": [{"ID": "hard-only", "Severity": "critical",
+    "Description": "soft mounts lose writes", "Require": {"Options": ["hard"]}}]}`), &policy)
+  if !assert.NoError(t, err) {
+    return
+  }
+  assert.Equal(t, nfsmountstats.SeverityCritical, policy.Rules[0].Severity)
+
+  dev := findDevice(t, mounts, "/mailhome6")
+  assert.Empty(t, policy.CheckDevice(dev))
+  delete(dev.NFSInfo.Options, "hard")
+  dev.NFSInfo.Options["soft"] = ""
+  findings := policy.CheckDevice(dev)
+  assert.Len(t, findings, 1)
+  assert.Equal(t, "option hard isn't set: soft mounts lose writes", findings[0].Message)
+}
+
+func TestPolicyVersionsCompareNumbers(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  policy := &nfsmountstats.Policy{Rules: []nfsmountstats.PolicyRule{
+    {ID: "v4", Severity: nfsmountstats.SeverityWarning, Require: nfsmountstats.PolicyConditions{Versions: []string{"4"}}},
+    {ID: "v4.0", Severity: nfsmountstats.SeverityWarning, Require: nfsmountstats.PolicyConditions{Versions: []string{"4.0"}}},
+  }}
+
+  // the kernel prints vers=4.0 for a v4.0 mount
+  dev := findDevice(t, mounts, "/mnt/nfs1/docs")
+  dev.NFSInfo.Options["vers"] = "4.0"
+  assert.Empty(t, policy.CheckDevice(dev))
+
+  // an nfs4 mount without a vers option
+  delete(dev.NFSInfo.Options, "vers")
+  assert.Empty(t, policy.CheckDevice(dev))
+
+  dev.NFSInfo.Options["vers"] = "4.2"
+  assert.Len(t, policy.CheckDevice(dev), 2)
+}
@@ -0,0 +1,179 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IDs of the findings made by ErrorDetector
+const (
+  FindingOpErrors = "op-errors" // an op is failing more than it should
+)
+
+// expectedErrorOps are ops that fail in normal use, so their errors are mostly
+// noise: LOOKUP of a name that doesn't exist (ENOENT, every negative dentry)
+// and the xattr ops of something that isn't there. ACCESS isn't one, a denial
+// comes back in a successful reply, so its errors are real failures like
+// ESTALE or EIO.
+var expectedErrorOps = map[string]bool{
+  "LOOKUP": true,
+  "LOOKUPP": true,
+  "GETXATTR": true,
+  "LISTXATTRS": true,
+}
+
+// ErrorStats summarizes the per-op error counters of a mount, either since the
+// mount was made or over an interval between two snapshots. The kernel only
+// counts errors, not which errno, so an op that fails a lot in normal use is
+// marked Expected rather than told apart from one that's really failing.
+// Only newer kernels print the errors column, when HasErrStats is false every
+// error count is 0 because it's unknown, not because nothing failed.
+type ErrorStats struct {
+  HasErrStats  bool
+  SampleTime   time.Duration // the time the rates are averaged over
+  Operations   uint64
+  Errors       uint64
+  ErrorRatio   float64 // Errors / Operations
+  ErrorsPerSec float64
+  Ops          []OpErrors // ops that failed, most errors first
+}
+
+// OpErrors is the errors of a single op.
+type OpErrors struct {
+  Op           string
+  Operations   uint64
+  Errors       uint64
+  ErrorRatio   float64 // Errors / Operations
+  ErrorsPerSec float64
+  Share        float64 // Errors as a ratio of the errors of every op, between 0 and 1
+  Expected     bool    // the op fails in normal use, eg: LOOKUP of a name that doesn't exist
+}
+
+// ErrorStats summarizes the errors since the mount was made, with rates
+// averaged over its Age.
+func (i *NFSInfo) ErrorStats() ErrorStats {
+  return newErrorStats(i, time.Duration(i.Age)*time.Second)
+}
+
+// ErrorStats summarizes the errors over the Interval of the delta.
+func (d *MountDeviceDelta) ErrorStats() ErrorStats {
+  return newErrorStats(&d.NFSInfo, d.Interval)
+}
+
+// newErrorStats summarizes the error counters in `info`, which are either
+// cumulative or a delta, over `sample`.
+func newErrorStats(info *NFSInfo, sample time.Duration) ErrorStats {
+  stats := ErrorStats{HasErrStats: info.HasErrStats, SampleTime: sample}
+  seconds := sample.Seconds()
+
+  for _, op := range info.OpNames() {
+    s := info.RPCOpStats[op]
+    stats.Operations += s.Operations
+    stats.Errors += s.ErrStats
+    if s.ErrStats == 0 {
+      continue
+    }
+    stats.Ops = append(stats.Ops, OpErrors{
+      Op: op,
+      Operations: s.Operations,
+      Errors: s.ErrStats,
+      ErrorRatio: ratio(s.ErrStats, s.Operations),
+      ErrorsPerSec: perSecond(s.ErrStats, seconds),
+      Expected: expectedErrorOps[op],
+    })
+  }
+  stats.ErrorRatio = ratio(stats.Errors, stats.Operations)
+  stats.ErrorsPerSec = perSecond(stats.Errors, seconds)
+
+  for idx := range stats.Ops {
+    stats.Ops[idx].Share = ratio(stats.Ops[idx].Errors, stats.Errors)
+  }
+  sort.SliceStable(stats.Ops, func(a, b int) bool {
+    return stats.Ops[a].Errors > stats.Ops[b].Errors
+  })
+
+  return stats
+}
+
+// ErrorDetector flags ops that fail too often over an interval. Ops whose
+// errors are Expected only warn at ExpectedWarnPercent, as a good share of
+// LOOKUPs failing is just applications looking for files that aren't there.
+// Mounts without the errors column are skipped.
+type ErrorDetector struct {
+  WarnPercent         float64 // errors as a percentage of ops that warn
+  CriticalPercent     float64 // errors as a percentage of ops that are critical
+  ExpectedWarnPercent float64 // errors as a percentage of ops that warn for Expected ops, which are never critical
+  MinOps              uint64  // ops with fewer operations in the interval are ignored
+}
+
+// NewErrorDetector constructs an ErrorDetector with the defaults, warning at
+// 1% errors and critical at 10%, or warning at 50% for ops that fail in normal
+// use, for ops that ran at least 10 times.
+func NewErrorDetector() *ErrorDetector {
+  return &ErrorDetector{
+    WarnPercent: 1,
+    CriticalPercent: 10,
+    ExpectedWarnPercent: 50,
+    MinOps: 10,
+  }
+}
+
+// Check runs the detector over every device of the delta. Devices that are
+// New or were remounted are skipped, their counters are since mount.
+func (e *ErrorDetector) Check(delta *MountstatsDelta) []Finding {
+  var findings []Finding
+  for idx := range delta.Devices {
+    if delta.Devices[idx].sinceMount() {
+      continue
+    }
+    findings = append(findings, e.CheckDevice(&delta.Devices[idx])...)
+  }
+  SortFindings(findings)
+
+  return findings
+}
+
+// CheckDevice runs the detector over a single device delta, which like
+// RetransDetector.CheckDevice shouldn't be a New or remounted device's.
+func (e *ErrorDetector) CheckDevice(d *MountDeviceDelta) []Finding {
+  stats := d.ErrorStats()
+  if !stats.HasErrStats {
+    return nil
+  }
+
+  var findings []Finding
+  for _, op := range stats.Ops {
+    if op.Operations < e.MinOps {
+      continue
+    }
+
+    percent := op.ErrorRatio * 100
+    finding := Finding{
+      ID: FindingOpErrors,
+      Device: d.Device,
+      Mountpoint: d.Mountpoint,
+      Op: op.Op,
+      Value: percent,
+    }
+    switch {
+    case op.Expected && percent >= e.ExpectedWarnPercent:
+      finding.Severity = SeverityWarning
+      finding.Threshold = e.ExpectedWarnPercent
+    case op.Expected:
+      continue
+    case percent >= e.CriticalPercent:
+      finding.Severity = SeverityCritical
+      finding.Threshold = e.CriticalPercent
+    case percent >= e.WarnPercent:
+      finding.Severity = SeverityWarning
+      finding.Threshold = e.WarnPercent
+    default:
+      continue
+    }
+    finding.Message = fmt.Sprintf("%s failed %.2f%% of requests (%d of %d)", op.Op, percent, op.Errors, op.Operations)
+    findings = append(findings, finding)
+  }
+
+  return findings
+}
@@ -0,0 +1,105 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatsSinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  stats := findDevice(t, mounts, "/mnt/nfs1/docs").NFSInfo.ErrorStats()
+  assert.True(t, stats.HasErrStats)
+  assert.Equal(t, 258103*time.Second, stats.SampleTime)
+  assert.Equal(t, uint64(35049), stats.Operations)
+  assert.Equal(t, uint64(2253), stats.Errors)
+  assert.InDelta(t, 2253.0/258103.0, stats.ErrorsPerSec, 0.000001)
+
+  // LOOKUP: 5109 5109 0 1274016 1197256 96 9244 9647 1728
+  assert.Len(t, stats.Ops, 9)
+  lookup := stats.Ops[0]
+  assert.Equal(t, "LOOKUP", lookup.Op)
+  assert.True(t, lookup.Expected)
+  assert.InDelta(t, 1728.0/5109.0, lookup.ErrorRatio, 0.000001)
+  assert.InDelta(t, 1728.0/2253.0, lookup.Share, 0.000001)
+  assert.Equal(t, "OPEN", stats.Ops[1].Op)
+  assert.False(t, stats.Ops[1].Expected)
+
+  // no errors column, so nothing is known about errors
+  stats = findDevice(t, mounts, "/mailhome6").NFSInfo.ErrorStats()
+  assert.False(t, stats.HasErrStats)
+  assert.Empty(t, stats.Ops)
+}
+
+// errorDelta returns the delta of `mountpoint` over 10s where each op ran 100
+// times with the given errors.
+func errorDelta(t *testing.T, mountpoint string, errors map[string]uint64) *nfsmountstats.MountstatsDelta {
+  t.Helper()
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, mountpoint)
+    dev.NFSInfo.Age += 10
+    for op, errs := range errors {
+      s := dev.NFSInfo.RPCOpStats[op]
+      s.Operations += 100
+      s.ErrStats += errs
+      dev.NFSInfo.RPCOpStats[op] = s
+    }
+  })
+
+  return delta
+}
+
+func TestErrorDetector(t *testing.T) {
+  detector := nfsmountstats.NewErrorDetector()
+
+  delta := errorDelta(t, "/mnt/nfs1/docs", map[string]uint64{"OPEN": 20, "CLOSE": 2, "LOOKUP": 30})
+  stats := delta.GetMountMap()["/mnt/nfs1/docs"].ErrorStats()
+  assert.Equal(t, uint64(52), stats.Errors)
+  assert.InDelta(t, 5.2, stats.ErrorsPerSec, 0.000001)
+
+  // 30% of LOOKUPs failing is noise
+  findings := detector.Check(delta)
+  assert.Len(t, findings, 2)
+  assert.Equal(t, nfsmountstats.FindingOpErrors, findings[0].ID)
+  assert.Equal(t, nfsmountstats.SeverityCritical, findings[0].Severity)
+  assert.Equal(t, "OPEN", findings[0].Op)
+  assert.InDelta(t, 20.0, findings[0].Value, 0.000001)
+  assert.Equal(t, 10.0, findings[0].Threshold)
+  assert.Equal(t, nfsmountstats.SeverityWarning, findings[1].Severity)
+  assert.Equal(t, "CLOSE", findings[1].Op)
+
+  // but most of them failing isn't
+  findings = detector.Check(errorDelta(t, "/mnt/nfs1/docs", map[string]uint64{"LOOKUP": 60}))
+  assert.Len(t, findings, 1)
+  assert.Equal(t, nfsmountstats.SeverityWarning, findings[0].Severity)
+  assert.Equal(t, 50.0, findings[0].Threshold)
+
+  // ACCESS denials are in successful replies, so its errors are real failures
+  findings = detector.Check(errorDelta(t, "/mnt/nfs1/docs", map[string]uint64{"ACCESS": 20}))
+  assert.Len(t, findings, 1)
+  assert.Equal(t, nfsmountstats.SeverityCritical, findings[0].Severity)
+  assert.Equal(t, "ACCESS", findings[0].Op)
+
+  // a mount without the errors column is skipped
+  assert.Empty(t, detector.Check(errorDelta(t, "/mailhome6", map[string]uint64{"READ": 50})))
+}
+
+func TestErrorDetectorSkipsNewMounts(t *testing.T) {
+  detector := nfsmountstats.NewErrorDetector()
+
+  // mounted between the snapshots, every error since mount is in the delta
+  prev, cur := loadTestSnapshots(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mnt/nfs1/docs")
+    open := dev.NFSInfo.RPCOpStats["OPEN"]
+    open.ErrStats += 1000
+    dev.NFSInfo.RPCOpStats["OPEN"] = open
+  })
+  removeDevice(t, prev, "/mnt/nfs1/docs")
+
+  delta := cur.Delta(prev)
+  assert.Empty(t, detector.Check(delta))
+  assert.NotEmpty(t, detector.CheckDevice(delta.GetMountMap()["/mnt/nfs1/docs"]))
+}
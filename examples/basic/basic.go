@@ -34,11 +34,13 @@ func main() {
     writeStats := mount.NFSInfo.RPCOpStats["WRITE"]
 
     // READ and WRITE operations are generally all _file_ related ops, so 
-    // it's the majority of what we care about here. you need to sum both 
-    // bytes fields for each op to get total throughput
-    // for metadata ops, sumt
-    readBytes := readStats.BytesSent + readStats.BytesReceived
-    writeBytes := writeStats.BytesSent + writeStats.BytesReceived
+    // it's the majority of what we care about here. the data of a READ comes 
+    // back in the reply and the data of a WRITE goes out in the request, so 
+    // that's the direction to count for throughput. summing both directions 
+    // (like nfsiostat's kB/s, see MountDevice.IOStats) also counts the rpc 
+    // headers going the other way.
+    readBytes := readStats.BytesReceived
+    writeBytes := writeStats.BytesSent
 
     // ops counters are simple
     readOps := readStats.Operations
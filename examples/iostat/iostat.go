@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+)
+
+func main() {
+  // print the same per mount read/write stats that nfsiostat does, once 
+  // since mount and then for a 5 second interval 
+  first, err := nfsmountstats.NewMountstats()
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // the first report is averaged over the age of each mount 
+  for _, mount := range first.GetNFSDevices() {
+    printIOStats(mount.IOStats())
+  }
+
+  time.Sleep(5 * time.Second)
+  second, err := nfsmountstats.NewMountstats()
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  // every report after that is the delta between two snapshots, averaged 
+  // over the time between them 
+  delta := second.Delta(first)
+  for _, mount := range delta.Devices {
+    printIOStats(mount.IOStats())
+  }
+}
+
+func printIOStats(stats nfsmountstats.MountIOStats) {
+  fmt.Printf("\n%s mounted on %s:\n\n", stats.Device, stats.Mountpoint)
+  fmt.Printf("%12s%12s\n", "ops/s", "rpc bklog")
+  fmt.Printf("%12.3f%12.3f\n", stats.OpsPerSec, stats.RPCBacklog)
+
+  for _, name := range []string{"READ", "WRITE"} {
+    op := stats.Ops[name]
+    fmt.Printf("\n%-13s%12s%12s%12s%14s%14s%12s%12s%12s\n", name+":", "ops/s", "kB/s", "kB/op", 
+      "retrans", "avg RTT (ms)", "avg exe (ms)", "avg queue (ms)", "errors")
+    fmt.Printf("%-13s%12.3f%12.3f%12.3f%7d (%3.1f%%)%14.3f%14.3f%12.3f%7d (%3.1f%%)\n", "", 
+      op.OpsPerSec, op.KBPerSec, op.KBPerOp, op.Retrans, op.RetransPercent, 
+      op.AvgRTT, op.AvgExe, op.AvgQueue, op.Errors, op.ErrorsPercent)
+  }
+}
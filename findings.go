@@ -0,0 +1,93 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity grades how urgent a Finding is.
+type Severity int
+
+const (
+  SeverityInfo Severity = iota
+  SeverityWarning
+  SeverityCritical
+)
+
+// String returns the lowercase name of the severity, eg: `warning`.
+func (s Severity) String() string {
+  switch s {
+  case SeverityInfo:
+    return "info"
+  case SeverityWarning:
+    return "warning"
+  case SeverityCritical:
+    return "critical"
+  }
+
+  return "unknown"
+}
+
+// ParseSeverity parses the lowercase name of a severity, eg: `warning`.
+func ParseSeverity(name string) (Severity, error) {
+  for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+    if s.String() == name {
+      return s, nil
+    }
+  }
+
+  return SeverityInfo, fmt.Errorf("unknown severity: %q", name)
+}
+
+// MarshalText writes the severity as its name, so it reads well in JSON and
+// other text formats.
+func (s Severity) MarshalText() ([]byte, error) {
+  return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a severity written by MarshalText.
+func (s *Severity) UnmarshalText(text []byte) error {
+  parsed, err := ParseSeverity(string(text))
+  if err != nil {
+    return err
+  }
+  *s = parsed
+
+  return nil
+}
+
+// Finding is a single problem found by one of the detectors, structured so it
+// can be turned into an alert or a log line without parsing the Message.
+type Finding struct {
+  ID         string  // what was found, one of the Finding* constants
+  Severity   Severity
+  Device     string
+  Mountpoint string  // "" for findings about a transport shared by several mounts
+  Op         string  // the op the finding is about, if it's about a single op
+  Transport  string  // the transport the finding is about, if it's about one
+  Message    string  // human readable description
+  Value      float64 // the measured value that triggered the finding
+  Threshold  float64 // the threshold it was compared to
+}
+
+// SortFindings orders findings from most to least severe, then by mountpoint,
+// transport, op and ID, so that reports come out the same every time.
+func SortFindings(findings []Finding) {
+  sort.SliceStable(findings, func(i, j int) bool {
+    a, b := findings[i], findings[j]
+    if a.Severity != b.Severity {
+      return a.Severity > b.Severity
+    }
+    if a.Mountpoint != b.Mountpoint {
+      return a.Mountpoint < b.Mountpoint
+    }
+    if a.Transport != b.Transport {
+      return a.Transport < b.Transport
+    }
+    if a.Op != b.Op {
+      return a.Op < b.Op
+    }
+
+    return a.ID < b.ID
+  })
+}
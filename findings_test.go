@@ -0,0 +1,50 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeverityString(t *testing.T) {
+  assert.Equal(t, "info", nfsmountstats.SeverityInfo.String())
+  assert.Equal(t, "warning", nfsmountstats.SeverityWarning.String())
+  assert.Equal(t, "critical", nfsmountstats.SeverityCritical.String())
+  assert.Equal(t, "unknown", nfsmountstats.Severity(42).String())
+}
+
+func TestSortFindings(t *testing.T) {
+  findings := []nfsmountstats.Finding{
+    {ID: "b", Severity: nfsmountstats.SeverityWarning, Mountpoint: "/b"},
+    {ID: "a", Severity: nfsmountstats.SeverityInfo, Mountpoint: "/a"},
+    {ID: "c", Severity: nfsmountstats.SeverityCritical, Mountpoint: "/c"},
+    {ID: "d", Severity: nfsmountstats.SeverityWarning, Mountpoint: "/a", Op: "WRITE"},
+    {ID: "e", Severity: nfsmountstats.SeverityWarning, Mountpoint: "/a", Op: "READ"},
+    // transport findings have no mountpoint or op
+    {ID: "t", Severity: nfsmountstats.SeverityWarning, Transport: "tcp 10.0.47.9 port 840"},
+    {ID: "t", Severity: nfsmountstats.SeverityWarning, Transport: "tcp 10.0.2.31 port 0"},
+  }
+  nfsmountstats.SortFindings(findings)
+
+  var ids []string
+  for _, finding := range findings {
+    ids = append(ids, finding.ID+finding.Transport)
+  }
+  assert.Equal(t, []string{"c", "ttcp 10.0.2.31 port 0", "ttcp 10.0.47.9 port 840", "e", "d", "b", "a"}, ids)
+}
+
+func TestParseSeverity(t *testing.T) {
+  severity, err := nfsmountstats.ParseSeverity("critical")
+  assert.NoError(t, err)
+  assert.Equal(t, nfsmountstats.SeverityCritical, severity)
+
+  _, err = nfsmountstats.ParseSeverity("bad")
+  assert.Error(t, err)
+
+  text, err := nfsmountstats.SeverityWarning.MarshalText()
+  assert.NoError(t, err)
+  assert.Equal(t, "warning", string(text))
+  assert.NoError(t, severity.UnmarshalText(text))
+  assert.Equal(t, nfsmountstats.SeverityWarning, severity)
+}
@@ -0,0 +1,272 @@
+package nfsmountstats
+
+import (
+	"sort"
+)
+
+// GroupField is what GroupBy groups mounts on.
+type GroupField string
+
+const (
+  GroupByServer   GroupField = "server"   // the server address, see NFSServerAddress.Key
+  GroupByExport   GroupField = "export"   // the server address and export path, eg: `10.0.2.31:/volume1`
+  GroupByVersion  GroupField = "version"  // the NFS version, eg: `3` or `4.2`
+  GroupByProtocol GroupField = "protocol" // the transport protocol, eg: `tcp`
+)
+
+// MountGroup is the sum of the counters of every mount that shares the same
+// value of a GroupField.
+// The latencies are weighted by the number of ops, so a busy mount counts for
+// more than an idle one just like it does for the server, and are in
+// milliseconds. Mounts of one superblock report the same counters, so a group
+// sums them once for every superblock in the SharedMounts given to GroupBy.
+// Subdirectory mounts of one superblock can still land in different
+// GroupByExport groups, and each of those counts the whole superblock.
+// Transport counters aren't summed as a group can mix protocols, and mounts
+// sharing a transport would be counted more than once.
+type MountGroup struct {
+  Field       GroupField
+  Key         string
+  Mountpoints []string // the mounts in the group, sorted
+  Events      NFSEventCounters
+  Bytes       NFSByteCounters
+  RPCOpStats  map[string]RPCOpStat
+  Ops         map[string]OpLatency // latency of every op that ran
+  Operations  uint64               // ops of every kind
+  AvgRTT      float64              // over ops of every kind
+  AvgExe      float64
+  AvgQueue    float64
+}
+
+// OpLatency is the average latency of a single op, in milliseconds.
+type OpLatency struct {
+  Op         string
+  Operations uint64
+  AvgRTT     float64
+  AvgExe     float64
+  AvgQueue   float64
+}
+
+// GroupBy sums the counters since mount of every NFS mount by `field`, sorted
+// by the group key, counting every superblock in `shared` (see Shared) once.
+// Mounts that aren't in `shared` count on their own.
+func (m *Mountstats) GroupBy(field GroupField, shared SharedMounts) []MountGroup {
+  var members []groupMember
+  for _, dev := range m.GetNFSDevices() {
+    members = append(members, groupMember{dev.Device, dev.Mountpoint, dev.MountType, &dev.NFSInfo})
+  }
+
+  return groupMembers(field, shared, members)
+}
+
+// GroupBy sums the counters of every device delta by `field`, sorted by the
+// group key, counting every superblock in `shared` once. `shared` should come
+// from the newer of the two snapshots. Devices that are New or were remounted
+// are left out, their counters are since mount rather than over the Interval
+// and would swamp the rest of their group.
+func (d *MountstatsDelta) GroupBy(field GroupField, shared SharedMounts) []MountGroup {
+  var members []groupMember
+  for idx := range d.Devices {
+    dev := &d.Devices[idx]
+    if dev.sinceMount() {
+      continue
+    }
+    members = append(members, groupMember{dev.Device, dev.Mountpoint, dev.MountType, &dev.NFSInfo})
+  }
+
+  return groupMembers(field, shared, members)
+}
+
+// groupMember is what GroupBy needs to know about a mount or a delta.
+type groupMember struct {
+  device     string
+  mountpoint string
+  mountType  string
+  info       *NFSInfo
+}
+
+// key returns the value of `field` for the member.
+func (g groupMember) key(field GroupField) string {
+  switch field {
+  case GroupByServer, GroupByExport:
+    server := g.device
+    if addr, err := newNFSServerAddress(g.device, g.info.Options); err == nil {
+      server = addr.Key()
+    }
+    if field == GroupByServer {
+      return server
+    }
+    if _, export, err := splitNFSDevice(g.device); err == nil {
+      return server + ":" + export
+    }
+    return server
+  case GroupByVersion:
+    if version := nfsVersion(g.mountType, g.info.Options); version != "" {
+      return version
+    }
+  case GroupByProtocol:
+    if g.info.Transport != nil {
+      return g.info.Transport.Protocol()
+    }
+    if proto := g.info.Options.Get("proto"); proto != "" {
+      return proto
+    }
+  }
+
+  return "unknown"
+}
+
+// nfsVersion returns the NFS version of a mount from its options, eg: `3` or
+// `4.2`, or "" if they don't say.
+func nfsVersion(mountType string, options NFSMountOptions) string {
+  for _, name := range []string{"vers", "nfsvers"} {
+    if version := options.Get(name); version != "" {
+      return version
+    }
+  }
+  if mountType == "nfs4" {
+    return "4"
+  }
+
+  return ""
+}
+
+// groupMembers sums the members into groups on `field`, one member of each
+// superblock in `shared`.
+func groupMembers(field GroupField, shared SharedMounts, members []groupMember) []MountGroup {
+  superblockOf := sharedGroupIndex(shared.Superblocks)
+  counted := make(map[string]bool)
+  index := make(map[string]int)
+  var groups []MountGroup
+  for _, member := range members {
+    key := member.key(field)
+    idx, ok := index[key]
+    if !ok {
+      idx = len(groups)
+      index[key] = idx
+      groups = append(groups, MountGroup{
+        Field: field,
+        Key: key,
+        RPCOpStats: make(map[string]RPCOpStat),
+      })
+    }
+
+    group := &groups[idx]
+    group.Mountpoints = append(group.Mountpoints, member.mountpoint)
+    superblock, ok := superblockOf[member.mountpoint]
+    if !ok {
+      superblock = "mount:" + member.mountpoint
+    }
+    if counted[key+" "+superblock] {
+      continue
+    }
+    counted[key+" "+superblock] = true
+    group.Events = group.Events.add(member.info.Events)
+    group.Bytes = group.Bytes.add(member.info.Bytes)
+    for op, s := range member.info.RPCOpStats {
+      group.RPCOpStats[op] = group.RPCOpStats[op].add(s)
+    }
+  }
+
+  for idx := range groups {
+    groups[idx].computeLatency()
+  }
+  sort.Slice(groups, func(i, j int) bool {
+    return groups[i].Key < groups[j].Key
+  })
+
+  return groups
+}
+
+// computeLatency derives the weighted latencies from the summed per-op stats.
+func (g *MountGroup) computeLatency() {
+  sort.Strings(g.Mountpoints)
+  g.Ops = make(map[string]OpLatency)
+
+  var total RPCOpStat
+  for op, s := range g.RPCOpStats {
+    total = total.add(s)
+    if s.Operations == 0 {
+      continue
+    }
+    g.Ops[op] = newOpLatency(op, s)
+  }
+
+  overall := newOpLatency("", total)
+  g.Operations = overall.Operations
+  g.AvgRTT, g.AvgExe, g.AvgQueue = overall.AvgRTT, overall.AvgExe, overall.AvgQueue
+}
+
+// newOpLatency averages the cumulative times of `s` over its ops.
+func newOpLatency(op string, s RPCOpStat) OpLatency {
+  ops := s.Operations
+  return OpLatency{
+    Op: op,
+    Operations: ops,
+    AvgRTT: ratio(s.CumRespTime, ops),
+    AvgExe: ratio(s.CumTotalReqTime, ops),
+    AvgQueue: ratio(s.CumQueueTime, ops),
+  }
+}
+
+// add returns the sum of two sets of event counters.
+func (e NFSEventCounters) add(o NFSEventCounters) NFSEventCounters {
+  return NFSEventCounters{
+    InodeRevalidates: e.InodeRevalidates + o.InodeRevalidates,
+    DentryRevalidates: e.DentryRevalidates + o.DentryRevalidates,
+    DataInvalidates: e.DataInvalidates + o.DataInvalidates,
+    AttrInvalidates: e.AttrInvalidates + o.AttrInvalidates,
+    VfsOpen: e.VfsOpen + o.VfsOpen,
+    VfsLookup: e.VfsLookup + o.VfsLookup,
+    VfsPermission: e.VfsPermission + o.VfsPermission,
+    VfsUpdatePage: e.VfsUpdatePage + o.VfsUpdatePage,
+    VfsReadPage: e.VfsReadPage + o.VfsReadPage,
+    VfsReadPages: e.VfsReadPages + o.VfsReadPages,
+    VfsWritePage: e.VfsWritePage + o.VfsWritePage,
+    VfsWritePages: e.VfsWritePages + o.VfsWritePages,
+    VfsReaddir: e.VfsReaddir + o.VfsReaddir,
+    VfsSetAttr: e.VfsSetAttr + o.VfsSetAttr,
+    VfsFlush: e.VfsFlush + o.VfsFlush,
+    VfsFsync: e.VfsFsync + o.VfsFsync,
+    VfsLock: e.VfsLock + o.VfsLock,
+    VfsRelease: e.VfsRelease + o.VfsRelease,
+    CongestionWait: e.CongestionWait + o.CongestionWait,
+    SetAttrTrunc: e.SetAttrTrunc + o.SetAttrTrunc,
+    ExtendWrite: e.ExtendWrite + o.ExtendWrite,
+    SillyRenames: e.SillyRenames + o.SillyRenames,
+    ShortReads: e.ShortReads + o.ShortReads,
+    ShortWrites: e.ShortWrites + o.ShortWrites,
+    Delay: e.Delay + o.Delay,
+    PNFSRead: e.PNFSRead + o.PNFSRead,
+    PNFSWrite: e.PNFSWrite + o.PNFSWrite,
+  }
+}
+
+// add returns the sum of two sets of byte counters.
+func (b NFSByteCounters) add(o NFSByteCounters) NFSByteCounters {
+  return NFSByteCounters{
+    NormalReadBytes: b.NormalReadBytes + o.NormalReadBytes,
+    NormalWriteBytes: b.NormalWriteBytes + o.NormalWriteBytes,
+    DirectReadBytes: b.DirectReadBytes + o.DirectReadBytes,
+    DirectWriteBytes: b.DirectWriteBytes + o.DirectWriteBytes,
+    ServerReadBytes: b.ServerReadBytes + o.ServerReadBytes,
+    ServerWriteBytes: b.ServerWriteBytes + o.ServerWriteBytes,
+    ReadPages: b.ReadPages + o.ReadPages,
+    WritePages: b.WritePages + o.WritePages,
+  }
+}
+
+// add returns the sum of the counters of two ops.
+func (s RPCOpStat) add(o RPCOpStat) RPCOpStat {
+  return RPCOpStat{
+    Operations: s.Operations + o.Operations,
+    Transmissions: s.Transmissions + o.Transmissions,
+    MajorTimeouts: s.MajorTimeouts + o.MajorTimeouts,
+    BytesSent: s.BytesSent + o.BytesSent,
+    BytesReceived: s.BytesReceived + o.BytesReceived,
+    CumQueueTime: s.CumQueueTime + o.CumQueueTime,
+    CumRespTime: s.CumRespTime + o.CumRespTime,
+    CumTotalReqTime: s.CumTotalReqTime + o.CumTotalReqTime,
+    ErrStats: s.ErrStats + o.ErrStats,
+  }
+}
@@ -0,0 +1,117 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupByServer(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  groups := mounts.GroupBy(nfsmountstats.GroupByServer, mounts.Shared(loadTestMountinfo(t)))
+  assert.Len(t, groups, 3)
+  assert.Equal(t, "10.0.2.31", groups[0].Key)
+  assert.Equal(t, nfsmountstats.GroupByServer, groups[0].Field)
+  assert.Equal(t, nfs1Mounts, groups[0].Mountpoints)
+
+  // four mounts of one superblock, GETATTR: 13920 13924 0 3187904 3394844 6668 27030 34563 7
+  // is only counted once
+  getattr := groups[0].RPCOpStats["GETATTR"]
+  assert.Equal(t, uint64(13920), getattr.Operations)
+  assert.Equal(t, uint64(7), getattr.ErrStats)
+  assert.InDelta(t, 27030.0/13920.0, groups[0].Ops["GETATTR"].AvgRTT, 0.0001)
+  assert.Equal(t, uint64(9263), groups[0].Events.VfsOpen)
+  assert.Equal(t, uint64(11208171), groups[0].Bytes.ServerReadBytes)
+
+  // without knowing what's shared every mount counts on its own
+  naive := mounts.GroupBy(nfsmountstats.GroupByServer, nfsmountstats.SharedMounts{})
+  assert.Equal(t, uint64(4*13920), naive[0].RPCOpStats["GETATTR"].Operations)
+
+  assert.Equal(t, "10.0.47.9", groups[1].Key)
+  assert.Len(t, groups[1].Mountpoints, 5)
+  assert.Equal(t, "192.168.147.7", groups[2].Key)
+}
+
+func TestGroupByWeightedLatency(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // the latency is weighted by ops, not an average of each mount's average:
+  // /mailhome6 45093115 ops at 1.83ms, four mounts of 13239441 ops at 2.54ms
+  // and /webmail0 34144430 ops at 0.27ms
+  groups := mounts.GroupBy(nfsmountstats.GroupByVersion, mounts.Shared(loadTestMountinfo(t)))
+  assert.Len(t, groups, 2)
+  assert.Equal(t, "3", groups[0].Key)
+  assert.Equal(t, uint64(132195309), groups[0].Operations)
+  assert.InDelta(t, 1.708916, groups[0].AvgRTT, 0.000001)
+  assert.InDelta(t, 14338816.0/54020252.0, groups[0].Ops["GETATTR"].AvgRTT, 0.000001)
+  assert.Equal(t, "4.2", groups[1].Key)
+
+  // ops that never ran aren't in Ops
+  _, ok := groups[1].Ops["READLINK"]
+  assert.False(t, ok)
+}
+
+func TestGroupByExportAndProtocol(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  exports := mounts.GroupBy(nfsmountstats.GroupByExport, nfsmountstats.SharedMounts{})
+  assert.Len(t, exports, 10)
+  assert.Equal(t, "10.0.2.31:/volume1/Public/code", exports[0].Key)
+
+  protocols := mounts.GroupBy(nfsmountstats.GroupByProtocol, nfsmountstats.SharedMounts{})
+  var keys []string
+  for _, group := range protocols {
+    keys = append(keys, group.Key)
+  }
+  assert.Equal(t, []string{"rdma", "tcp", "udp"}, keys)
+  assert.Len(t, protocols[1].Mountpoints, 8)
+}
+
+func TestGroupByDelta(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    for _, mountpoint := range []string{"/mnt/nfs1/docs", "/mnt/nfs1/code"} {
+      dev := findDevice(t, cur, mountpoint)
+      dev.NFSInfo.Age += 10
+      read := dev.NFSInfo.RPCOpStats["READ"]
+      read.Operations += 100
+      dev.NFSInfo.RPCOpStats["READ"] = read
+    }
+    // one fast and one slow
+    docs := findDevice(t, cur, "/mnt/nfs1/docs").NFSInfo.RPCOpStats["READ"]
+    docs.CumRespTime += 100
+    findDevice(t, cur, "/mnt/nfs1/docs").NFSInfo.RPCOpStats["READ"] = docs
+    code := findDevice(t, cur, "/mnt/nfs1/code").NFSInfo.RPCOpStats["READ"]
+    code.CumRespTime += 900
+    findDevice(t, cur, "/mnt/nfs1/code").NFSInfo.RPCOpStats["READ"] = code
+  })
+
+  // counted on their own, they can't be one superblock with different counters
+  groups := delta.GroupBy(nfsmountstats.GroupByServer, nfsmountstats.SharedMounts{})
+  assert.Equal(t, "10.0.2.31", groups[0].Key)
+  assert.Equal(t, uint64(200), groups[0].Operations)
+  assert.InDelta(t, 5.0, groups[0].Ops["READ"].AvgRTT, 0.0001)
+  assert.InDelta(t, 5.0, groups[0].AvgRTT, 0.0001)
+  assert.Equal(t, uint64(0), groups[1].Operations)
+}
+
+func TestGroupByDeltaSkipsNewMounts(t *testing.T) {
+  prev, cur := loadTestSnapshots(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome5")
+    dev.NFSInfo.Age += 10
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations += 100
+    dev.NFSInfo.RPCOpStats["READ"] = read
+  })
+  // /mailhome6 was mounted between the snapshots, its counters are since mount
+  removeDevice(t, prev, "/mailhome6")
+
+  for _, group := range cur.Delta(prev).GroupBy(nfsmountstats.GroupByServer, cur.Shared(loadTestMountinfo(t))) {
+    assert.NotContains(t, group.Mountpoints, "/mailhome6")
+    if group.Key == "10.0.47.9" {
+      assert.Equal(t, uint64(100), group.Operations)
+    }
+  }
+}
@@ -0,0 +1,341 @@
+package nfsmountstats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// the periods of the load average style EWMAs kept by History
+var loadAveragePeriods = [3]time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// History keeps a bounded ring buffer of recent snapshots for every NFS mount
+// so that questions like "how has this mount behaved over the last 5 minutes"
+// can be answered without keeping every snapshot around.
+// Mounts are keyed on their mountpoint, if a different device gets mounted on
+// the same path its history starts over, and mounts that disappear from a
+// snapshot are forgotten. History is safe for concurrent use, eg: a Sampler
+// adding snapshots while an exporter queries windows.
+// Added snapshots are kept by reference and mustn't be modified afterwards.
+type History struct {
+  Capacity int // snapshots kept per mount
+
+  mu     sync.RWMutex
+  mounts map[string]*mountHistory
+}
+
+// MountWindow aggregates the history of a single mount over a time window.
+type MountWindow struct {
+  Mountpoint string
+  Start      time.Time                  // timestamp of the oldest snapshot in the window
+  End        time.Time                  // timestamp of the newest snapshot in the window
+  Samples    int                        // snapshots in the window
+  Delta      MountDeviceDelta           // change in every counter from Start to End
+  Rates      MountDeviceRates           // Delta per second
+  Ops        map[string]OpLatencyWindow // per op latency, only ops that ran in the window
+}
+
+// OpLatencyWindow is the latency of a single op over a window, in milliseconds.
+// The averages are over every op in the window, the min and max are of the
+// average latency in each interval between snapshots where the op ran.
+type OpLatencyWindow struct {
+  Op         string
+  Operations uint64
+  AvgRTT     float64
+  MinRTT     float64
+  MaxRTT     float64
+  AvgExe     float64
+  MinExe     float64
+  MaxExe     float64
+  AvgQueue   float64
+  MinQueue   float64
+  MaxQueue   float64
+}
+
+// LoadAverage is an exponentially weighted moving average over 1, 5 and 15
+// minutes, in the style of the kernel load average.
+type LoadAverage struct {
+  One     float64
+  Five    float64
+  Fifteen float64
+}
+
+// MountLoad holds the load averages of a single mount, updated every time a
+// snapshot is added. The latencies are in milliseconds, averaged over all ops,
+// and hold their value through intervals where nothing ran.
+type MountLoad struct {
+  OpsPerSec        LoadAverage // rpc requests sent per second
+  RetransPerSec    LoadAverage
+  ReadBytesPerSec  LoadAverage // bytes read from the server
+  WriteBytesPerSec LoadAverage // bytes written to the server
+  AvgRTT           LoadAverage
+  AvgExe           LoadAverage
+}
+
+// historyPoint is a single device as it was in one snapshot.
+type historyPoint struct {
+  device    MountDevice
+  timestamp time.Time
+}
+
+// mountHistory is the ring buffer of a single mount.
+type mountHistory struct {
+  points []historyPoint
+  head   int // index of the oldest point
+  count  int
+  load   MountLoad
+  loaded bool // load has been seeded
+  timed  bool // the latency averages have been seeded, they need an interval where ops ran
+}
+
+// NewHistory constructs a History that keeps up to `capacity` snapshots per
+// mount, eg: 15 minutes of 5 second samples is a capacity of 181.
+func NewHistory(capacity int) *History {
+  return &History{
+    Capacity: capacity,
+    mounts: make(map[string]*mountHistory),
+  }
+}
+
+// Add records every NFS device of the snapshot in the history, and updates
+// the load averages with the change since the previous snapshot.
+// Returns an error if the snapshot has no Timestamp or is older than the last
+// one added, in which case nothing is recorded.
+func (h *History) Add(m *Mountstats) error {
+  if m.Timestamp.IsZero() {
+    return errors.New("can't add a snapshot without a timestamp to the history")
+  }
+  if h.Capacity < 2 {
+    return errors.New("history capacity must be at least 2 to compute anything")
+  }
+
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  if h.mounts == nil {
+    h.mounts = make(map[string]*mountHistory)
+  }
+  for _, mount := range h.mounts {
+    if last, ok := mount.last(); ok && m.Timestamp.Before(last.timestamp) {
+      return errors.New("snapshot is older than the last one in the history")
+    }
+  }
+
+  seen := make(map[string]bool)
+  for _, dev := range m.GetNFSDevices() {
+    seen[dev.Mountpoint] = true
+    mount, ok := h.mounts[dev.Mountpoint]
+    if last, exists := mount.last(); ok && exists && last.device.Device != dev.Device {
+      ok = false
+    }
+    if !ok || len(mount.points) != h.Capacity {
+      mount = &mountHistory{points: make([]historyPoint, h.Capacity)}
+      h.mounts[dev.Mountpoint] = mount
+    }
+    mount.add(historyPoint{device: *dev, timestamp: m.Timestamp})
+  }
+
+  for mountpoint := range h.mounts {
+    if !seen[mountpoint] {
+      delete(h.mounts, mountpoint)
+    }
+  }
+
+  return nil
+}
+
+// Mountpoints returns the mountpoints that have history.
+func (h *History) Mountpoints() []string {
+  h.mu.RLock()
+  defer h.mu.RUnlock()
+
+  mountpoints := make([]string, 0, len(h.mounts))
+  for mountpoint := range h.mounts {
+    mountpoints = append(mountpoints, mountpoint)
+  }
+
+  return mountpoints
+}
+
+// Load returns the load averages of the mount, false if there isn't enough
+// history for them yet.
+func (h *History) Load(mountpoint string) (MountLoad, bool) {
+  h.mu.RLock()
+  defer h.mu.RUnlock()
+
+  mount, ok := h.mounts[mountpoint]
+  if !ok || !mount.loaded {
+    return MountLoad{}, false
+  }
+
+  return mount.load, true
+}
+
+// Window aggregates the history of the mount over the last `window`, measured
+// back from its newest snapshot. A window longer than the history covers all
+// of it.
+// Returns an error if the mount has no history, or fewer than 2 snapshots
+// fall within the window.
+func (h *History) Window(mountpoint string, window time.Duration) (*MountWindow, error) {
+  h.mu.RLock()
+  defer h.mu.RUnlock()
+
+  mount, ok := h.mounts[mountpoint]
+  if !ok {
+    return nil, fmt.Errorf("no history for %s", mountpoint)
+  }
+
+  last, _ := mount.last()
+  start := last.timestamp.Add(-window)
+  var points []historyPoint
+  for idx := 0; idx < mount.count; idx++ {
+    point := mount.at(idx)
+    if !point.timestamp.Before(start) {
+      points = append(points, point)
+    }
+  }
+  if len(points) < 2 {
+    return nil, fmt.Errorf("not enough history for %s in the last %s", mountpoint, window)
+  }
+
+  first := points[0]
+  result := MountWindow{
+    Mountpoint: mountpoint,
+    Start: first.timestamp,
+    End: last.timestamp,
+    Samples: len(points),
+    Delta: pointDelta(first, last),
+    Ops: make(map[string]OpLatencyWindow),
+  }
+  result.Rates = result.Delta.Rates()
+
+  for op, s := range result.Delta.NFSInfo.RPCOpStats {
+    if s.Operations == 0 {
+      continue
+    }
+    ops := float64(s.Operations)
+    result.Ops[op] = OpLatencyWindow{
+      Op: op,
+      Operations: s.Operations,
+      AvgRTT: float64(s.CumRespTime) / ops,
+      MinRTT: math.Inf(1),
+      AvgExe: float64(s.CumTotalReqTime) / ops,
+      MinExe: math.Inf(1),
+      AvgQueue: float64(s.CumQueueTime) / ops,
+      MinQueue: math.Inf(1),
+    }
+  }
+
+  // min and max come from the interval between each pair of snapshots
+  for idx := 1; idx < len(points); idx++ {
+    delta := pointDelta(points[idx-1], points[idx])
+    for op, s := range delta.NFSInfo.RPCOpStats {
+      latency, ok := result.Ops[op]
+      if !ok || s.Operations == 0 {
+        continue
+      }
+      ops := float64(s.Operations)
+      rtt, exe, queue := float64(s.CumRespTime)/ops, float64(s.CumTotalReqTime)/ops, float64(s.CumQueueTime)/ops
+      latency.MinRTT, latency.MaxRTT = math.Min(latency.MinRTT, rtt), math.Max(latency.MaxRTT, rtt)
+      latency.MinExe, latency.MaxExe = math.Min(latency.MinExe, exe), math.Max(latency.MaxExe, exe)
+      latency.MinQueue, latency.MaxQueue = math.Min(latency.MinQueue, queue), math.Max(latency.MaxQueue, queue)
+      result.Ops[op] = latency
+    }
+  }
+
+  // an op that only ran across a reset never got an interval of its own
+  for op, latency := range result.Ops {
+    if math.IsInf(latency.MinRTT, 1) {
+      latency.MinRTT, latency.MaxRTT = latency.AvgRTT, latency.AvgRTT
+      latency.MinExe, latency.MaxExe = latency.AvgExe, latency.AvgExe
+      latency.MinQueue, latency.MaxQueue = latency.AvgQueue, latency.AvgQueue
+      result.Ops[op] = latency
+    }
+  }
+
+  return &result, nil
+}
+
+// pointDelta computes the delta of a device between two points in its history
+// the same way Mountstats.Delta does.
+func pointDelta(prev historyPoint, cur historyPoint) MountDeviceDelta {
+  prevStats := Mountstats{Devices: []MountDevice{prev.device}, Timestamp: prev.timestamp}
+  curStats := Mountstats{Devices: []MountDevice{cur.device}, Timestamp: cur.timestamp}
+
+  return curStats.Delta(&prevStats).Devices[0]
+}
+
+// last returns the newest point, false if there are none. Safe to call on nil.
+func (m *mountHistory) last() (historyPoint, bool) {
+  if m == nil || m.count == 0 {
+    return historyPoint{}, false
+  }
+
+  return m.at(m.count - 1), true
+}
+
+// at returns the idx'th oldest point.
+func (m *mountHistory) at(idx int) historyPoint {
+  return m.points[(m.head+idx)%len(m.points)]
+}
+
+// add appends a point, overwriting the oldest one once the buffer is full, and
+// folds the change since the previous point into the load averages.
+func (m *mountHistory) add(point historyPoint) {
+  if prev, ok := m.last(); ok {
+    m.updateLoad(pointDelta(prev, point))
+  }
+
+  if m.count < len(m.points) {
+    m.points[(m.head+m.count)%len(m.points)] = point
+    m.count++
+    return
+  }
+  m.points[m.head] = point
+  m.head = (m.head + 1) % len(m.points)
+}
+
+// updateLoad folds a delta into the load averages, the first delta seeds them.
+func (m *mountHistory) updateLoad(delta MountDeviceDelta) {
+  if delta.Interval <= 0 {
+    return
+  }
+  rates := delta.Rates()
+
+  var ops, retrans, rtt, exe uint64
+  for _, s := range delta.NFSInfo.RPCOpStats {
+    ops += s.Operations
+    if s.Transmissions > s.Operations {
+      retrans += s.Transmissions - s.Operations
+    }
+    rtt += s.CumRespTime
+    exe += s.CumTotalReqTime
+  }
+
+  seed := !m.loaded
+  m.loaded = true
+  m.load.OpsPerSec.update(rates.Transport.RpcSends, delta.Interval, seed)
+  m.load.RetransPerSec.update(perSecond(retrans, delta.Interval.Seconds()), delta.Interval, seed)
+  m.load.ReadBytesPerSec.update(rates.Bytes.ServerReadBytes, delta.Interval, seed)
+  m.load.WriteBytesPerSec.update(rates.Bytes.ServerWriteBytes, delta.Interval, seed)
+  if ops > 0 {
+    m.load.AvgRTT.update(float64(rtt)/float64(ops), delta.Interval, !m.timed)
+    m.load.AvgExe.update(float64(exe)/float64(ops), delta.Interval, !m.timed)
+    m.timed = true
+  }
+}
+
+// update folds a value observed over `interval` into the averages, or sets
+// them to it if `seed` is true.
+func (l *LoadAverage) update(value float64, interval time.Duration, seed bool) {
+  averages := [3]*float64{&l.One, &l.Five, &l.Fifteen}
+  for idx, average := range averages {
+    if seed {
+      *average = value
+      continue
+    }
+    decay := math.Exp(-interval.Seconds() / loadAveragePeriods[idx].Seconds())
+    *average = *average*decay + value*(1-decay)
+  }
+}
@@ -0,0 +1,161 @@
+package nfsmountstats_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+// addHistory adds a snapshot to the history `step` seconds after `start`, with
+// /mailhome6 having done `reads` more READs than in the testdata, each of
+// which took `rtt` milliseconds on the wire more than the ones before.
+func addHistory(t *testing.T, history *nfsmountstats.History, start time.Time, step int, reads uint64, rtt uint64) {
+  t.Helper()
+  mounts := loadTestMountstats(t)
+  mounts.Timestamp = start.Add(time.Duration(step) * time.Second)
+  dev := findDevice(t, mounts, "/mailhome6")
+  dev.NFSInfo.Age += uint64(step)
+  read := dev.NFSInfo.RPCOpStats["READ"]
+  read.Operations += reads
+  read.Transmissions += reads
+  read.CumRespTime += rtt
+  read.CumTotalReqTime += rtt
+  dev.NFSInfo.RPCOpStats["READ"] = read
+  xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+  xprt.RpcSends += reads
+
+  if err := history.Add(mounts); err != nil {
+    t.Fatalf("couldn't add snapshot to history: %v", err)
+  }
+}
+
+func TestHistoryWindow(t *testing.T) {
+  history := nfsmountstats.NewHistory(10)
+  start := time.Now()
+
+  // 100 reads every 10s, the middle interval at 5ms a read, the rest at 1ms
+  addHistory(t, history, start, 0, 0, 0)
+  addHistory(t, history, start, 10, 100, 100)
+  addHistory(t, history, start, 20, 200, 600)
+  addHistory(t, history, start, 30, 300, 700)
+
+  window, err := history.Window("/mailhome6", time.Minute)
+  if err != nil {
+    t.Fatalf("couldn't get window: %v", err)
+  }
+  assert.Equal(t, 4, window.Samples)
+  assert.Equal(t, 30*time.Second, window.End.Sub(window.Start))
+  assert.Equal(t, uint64(300), window.Delta.NFSInfo.RPCOpStats["READ"].Operations)
+  assert.InDelta(t, 10.0, window.Rates.RPCOpStats["READ"].Operations, 0.0001)
+
+  read := window.Ops["READ"]
+  assert.InDelta(t, 700.0/300.0, read.AvgRTT, 0.0001)
+  assert.InDelta(t, 1.0, read.MinRTT, 0.0001)
+  assert.InDelta(t, 5.0, read.MaxRTT, 0.0001)
+  assert.InDelta(t, 5.0, read.MaxExe, 0.0001)
+  _, ok := window.Ops["WRITE"]
+  assert.False(t, ok)
+
+  // only the last interval
+  window, err = history.Window("/mailhome6", 10*time.Second)
+  if err != nil {
+    t.Fatalf("couldn't get window: %v", err)
+  }
+  assert.Equal(t, 2, window.Samples)
+  assert.InDelta(t, 1.0, window.Ops["READ"].MaxRTT, 0.0001)
+
+  _, err = history.Window("/mailhome6", time.Second)
+  assert.Error(t, err)
+  _, err = history.Window("/nope", time.Minute)
+  assert.Error(t, err)
+}
+
+func TestHistoryCapacity(t *testing.T) {
+  history := nfsmountstats.NewHistory(3)
+  start := time.Now()
+  for step := 0; step < 6; step++ {
+    addHistory(t, history, start, step*10, uint64(step*100), 0)
+  }
+
+  // only the newest 3 snapshots are left
+  window, err := history.Window("/mailhome6", time.Hour)
+  if err != nil {
+    t.Fatalf("couldn't get window: %v", err)
+  }
+  assert.Equal(t, 3, window.Samples)
+  assert.Equal(t, start.Add(30*time.Second), window.Start)
+  assert.Equal(t, uint64(200), window.Delta.NFSInfo.RPCOpStats["READ"].Operations)
+}
+
+func TestHistoryLoad(t *testing.T) {
+  history := nfsmountstats.NewHistory(10)
+  start := time.Now()
+
+  addHistory(t, history, start, 0, 0, 0)
+  _, ok := history.Load("/mailhome6")
+  assert.False(t, ok)
+
+  // the first interval seeds the averages
+  addHistory(t, history, start, 10, 1000, 2000)
+  load, ok := history.Load("/mailhome6")
+  assert.True(t, ok)
+  assert.InDelta(t, 100.0, load.OpsPerSec.One, 0.0001)
+  assert.InDelta(t, 100.0, load.OpsPerSec.Fifteen, 0.0001)
+  assert.InDelta(t, 2.0, load.AvgRTT.Five, 0.0001)
+
+  // an idle interval decays the rate but leaves the latency alone, the short
+  // average decays faster than the long ones
+  addHistory(t, history, start, 70, 1000, 2000)
+  load, _ = history.Load("/mailhome6")
+  assert.InDelta(t, 100.0*math.Exp(-1), load.OpsPerSec.One, 0.0001)
+  assert.InDelta(t, 100.0*math.Exp(-60.0/300.0), load.OpsPerSec.Five, 0.0001)
+  assert.Less(t, load.OpsPerSec.One, load.OpsPerSec.Fifteen)
+  assert.InDelta(t, 2.0, load.AvgRTT.One, 0.0001)
+}
+
+func TestHistoryRemountAndUnmount(t *testing.T) {
+  history := nfsmountstats.NewHistory(10)
+  start := time.Now()
+  addHistory(t, history, start, 0, 0, 0)
+  addHistory(t, history, start, 10, 100, 100)
+  assert.Contains(t, history.Mountpoints(), "/mailhome6")
+
+  // a different export mounted on the same path starts over
+  mounts := loadTestMountstats(t)
+  mounts.Timestamp = start.Add(20 * time.Second)
+  findDevice(t, mounts, "/mailhome6").Device = "otherserver:/export"
+  assert.NoError(t, history.Add(mounts))
+  _, err := history.Window("/mailhome6", time.Hour)
+  assert.Error(t, err)
+
+  // mounts missing from a snapshot are forgotten
+  mounts = loadTestMountstats(t)
+  mounts.Timestamp = start.Add(30 * time.Second)
+  for idx := range mounts.Devices {
+    if mounts.Devices[idx].Mountpoint == "/mailhome6" {
+      mounts.Devices = append(mounts.Devices[:idx], mounts.Devices[idx+1:]...)
+      break
+    }
+  }
+  assert.NoError(t, history.Add(mounts))
+  assert.NotContains(t, history.Mountpoints(), "/mailhome6")
+  assert.Contains(t, history.Mountpoints(), "/webmail0")
+}
+
+func TestHistoryAddErrors(t *testing.T) {
+  history := nfsmountstats.NewHistory(10)
+  mounts := loadTestMountstats(t)
+  assert.Error(t, history.Add(mounts))
+
+  start := time.Now()
+  addHistory(t, history, start, 10, 0, 0)
+  older := loadTestMountstats(t)
+  older.Timestamp = start
+  assert.Error(t, history.Add(older))
+
+  small := nfsmountstats.NewHistory(1)
+  assert.Error(t, small.Add(older))
+}
@@ -0,0 +1,186 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IDs of the findings made by HungMountDetector
+const (
+  FindingHungMount     = "hung-mount"     // a mount's ops stopped completing while requests are outstanding
+  FindingHungTransport = "hung-transport" // a transport stopped getting replies, every mount on it is stuck
+)
+
+// HungMount is a mount that HungMountDetector currently considers stuck.
+type HungMount struct {
+  Device     string
+  Mountpoint string
+  Transport  string        // see MountDevice.TransportKey
+  Stuck      time.Duration // how long it's been stuck, at most one interval more than it really has
+  Sends      uint64        // rpc requests sent since it got stuck, retransmissions included
+}
+
+// HungMountDetector spots hard mounts whose server stopped responding, from
+// the counters alone so nothing ever touches the mount (which would hang too).
+// A mount gets stuck on an interval where the transport sent requests but
+// received no replies and none of its ops completed. It stays stuck for as long
+// as no replies arrive and no ops complete, even if nothing is sent, as a hard
+// mount only retransmits every `timeo` which is usually longer than a sampling
+// interval.
+// The detector keeps state between calls to Update, which should be given the
+// delta of every consecutive pair of snapshots.
+type HungMountDetector struct {
+  WarnAfter     time.Duration // stuck for this long is a warning
+  CriticalAfter time.Duration // stuck for this long is critical
+
+  mounts map[string]*HungMount // keyed on device and mountpoint
+}
+
+// NewHungMountDetector constructs a HungMountDetector that warns about mounts
+// stuck for 30s and goes critical after 2 minutes.
+func NewHungMountDetector() *HungMountDetector {
+  return &HungMountDetector{
+    WarnAfter: 30 * time.Second,
+    CriticalAfter: 2 * time.Minute,
+    mounts: make(map[string]*HungMount),
+  }
+}
+
+// Update folds the next delta into the detector's state and returns a finding
+// for every mount that's been stuck for at least WarnAfter, and for every
+// transport all of whose mounts are.
+func (h *HungMountDetector) Update(delta *MountstatsDelta) []Finding {
+  if h.mounts == nil {
+    h.mounts = make(map[string]*HungMount)
+  }
+
+  seen := make(map[string]bool)
+  // every mount on each transport, to tell whether the whole transport is stuck
+  transports := make(map[string][]string)
+  for idx := range delta.Devices {
+    d := &delta.Devices[idx]
+    key := d.Device + " " + d.Mountpoint
+    seen[key] = true
+    transport := d.TransportKey()
+    transports[transport] = append(transports[transport], key)
+
+    // counters since mount say nothing about the last interval
+    if d.sinceMount() || d.hasReset(ResetTransport) || d.NFSInfo.Transport == nil {
+      delete(h.mounts, key)
+      continue
+    }
+
+    health := d.TransportHealth()
+    var completed uint64
+    for _, s := range d.NFSInfo.RPCOpStats {
+      completed += s.Operations
+    }
+    frozen := health.Receives == 0 && completed == 0
+
+    mount, stuck := h.mounts[key]
+    switch {
+    case stuck && frozen:
+      mount.Stuck += d.Interval
+      mount.Sends += health.Sends
+    case frozen && health.Sends > 0:
+      h.mounts[key] = &HungMount{
+        Device: d.Device,
+        Mountpoint: d.Mountpoint,
+        Transport: transport,
+        Stuck: d.Interval,
+        Sends: health.Sends,
+      }
+    default:
+      delete(h.mounts, key)
+    }
+  }
+  for key := range h.mounts {
+    if !seen[key] {
+      delete(h.mounts, key)
+    }
+  }
+
+  var findings []Finding
+  for _, mount := range h.Hung() {
+    severity, threshold, ok := h.severity(mount.Stuck)
+    if !ok {
+      continue
+    }
+    findings = append(findings, Finding{
+      ID: FindingHungMount,
+      Severity: severity,
+      Device: mount.Device,
+      Mountpoint: mount.Mountpoint,
+      Transport: mount.Transport,
+      Message: fmt.Sprintf("server not responding for %s, %d requests sent without a reply", mount.Stuck, mount.Sends),
+      Value: mount.Stuck.Seconds(),
+      Threshold: threshold.Seconds(),
+    })
+  }
+
+  for transport, keys := range transports {
+    if transport == "" {
+      continue
+    }
+    // the transport is as stuck as its least stuck mount
+    var stuck time.Duration
+    var mountpoints []string
+    for idx, key := range keys {
+      mount, ok := h.mounts[key]
+      if !ok {
+        stuck = 0
+        break
+      }
+      if idx == 0 || mount.Stuck < stuck {
+        stuck = mount.Stuck
+      }
+      mountpoints = append(mountpoints, mount.Mountpoint)
+    }
+    severity, threshold, ok := h.severity(stuck)
+    if !ok {
+      continue
+    }
+    sort.Strings(mountpoints)
+    findings = append(findings, Finding{
+      ID: FindingHungTransport,
+      Severity: severity,
+      Transport: transport,
+      Message: fmt.Sprintf("no replies on %s for %s, affecting %s", transport, stuck, strings.Join(mountpoints, ", ")),
+      Value: stuck.Seconds(),
+      Threshold: threshold.Seconds(),
+    })
+  }
+  SortFindings(findings)
+
+  return findings
+}
+
+// Hung returns every mount currently considered stuck, however briefly, sorted
+// by mountpoint.
+func (h *HungMountDetector) Hung() []HungMount {
+  hung := make([]HungMount, 0, len(h.mounts))
+  for _, mount := range h.mounts {
+    hung = append(hung, *mount)
+  }
+  sort.Slice(hung, func(i, j int) bool {
+    return hung[i].Mountpoint < hung[j].Mountpoint
+  })
+
+  return hung
+}
+
+// severity grades a stuck duration, false if it's not worth a finding.
+func (h *HungMountDetector) severity(stuck time.Duration) (Severity, time.Duration, bool) {
+  switch {
+  case stuck <= 0:
+    return SeverityInfo, 0, false
+  case stuck >= h.CriticalAfter:
+    return SeverityCritical, h.CriticalAfter, true
+  case stuck >= h.WarnAfter:
+    return SeverityWarning, h.WarnAfter, true
+  }
+
+  return SeverityInfo, 0, false
+}
@@ -0,0 +1,125 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+// the mounts sharing the `tcp 10.0.47.9 port 840` transport in the testdata
+var sharedTransportMounts = []string{"/mailhome6", "/mailhome5", "/fakehomestatver1"}
+
+// hungSnapshot loads the testdata as it would be `step` 15s intervals after
+// `start`, with `mountpoints` having sent `sends` more requests and gotten
+// `replies` more replies (one GETATTR each) than in the testdata.
+func hungSnapshot(t *testing.T, start time.Time, step int, mountpoints []string, sends uint64, replies uint64) *nfsmountstats.Mountstats {
+  t.Helper()
+  mounts := loadTestMountstats(t)
+  mounts.Timestamp = start.Add(time.Duration(step) * 15 * time.Second)
+  for _, mountpoint := range mountpoints {
+    dev := findDevice(t, mounts, mountpoint)
+    dev.NFSInfo.Age += uint64(step * 15)
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += sends
+    xprt.RpcReceives += replies
+    getattr := dev.NFSInfo.RPCOpStats["GETATTR"]
+    getattr.Operations += replies
+    getattr.Transmissions += replies
+    dev.NFSInfo.RPCOpStats["GETATTR"] = getattr
+  }
+
+  return mounts
+}
+
+func TestHungMountDetector(t *testing.T) {
+  detector := nfsmountstats.NewHungMountDetector()
+  start := time.Now()
+  mounts := []string{"/mailhome6"}
+
+  // healthy, then requests go out and nothing comes back
+  snapshots := []*nfsmountstats.Mountstats{
+    hungSnapshot(t, start, 0, mounts, 0, 0),
+    hungSnapshot(t, start, 1, mounts, 100, 100),
+    hungSnapshot(t, start, 2, mounts, 110, 100),
+    // nothing sent while waiting for the timeout to retransmit
+    hungSnapshot(t, start, 3, mounts, 110, 100),
+    hungSnapshot(t, start, 4, mounts, 111, 100),
+  }
+
+  assert.Empty(t, detector.Update(snapshots[1].Delta(snapshots[0])))
+  assert.Empty(t, detector.Hung())
+
+  // stuck, but not for long enough to say so
+  assert.Empty(t, detector.Update(snapshots[2].Delta(snapshots[1])))
+  hung := detector.Hung()
+  assert.Len(t, hung, 1)
+  assert.Equal(t, "/mailhome6", hung[0].Mountpoint)
+  assert.Equal(t, "tcp 10.0.47.9 port 840", hung[0].Transport)
+  assert.Equal(t, 15*time.Second, hung[0].Stuck)
+
+  findings := detector.Update(snapshots[3].Delta(snapshots[2]))
+  assert.Len(t, findings, 1)
+  assert.Equal(t, nfsmountstats.FindingHungMount, findings[0].ID)
+  assert.Equal(t, nfsmountstats.SeverityWarning, findings[0].Severity)
+  assert.Equal(t, 30.0, findings[0].Value)
+
+  detector.Update(snapshots[4].Delta(snapshots[3]))
+  hung = detector.Hung()
+  assert.Equal(t, 45*time.Second, hung[0].Stuck)
+  assert.Equal(t, uint64(11), hung[0].Sends)
+
+  // the server comes back
+  recovered := hungSnapshot(t, start, 5, mounts, 120, 120)
+  assert.Empty(t, detector.Update(recovered.Delta(snapshots[4])))
+  assert.Empty(t, detector.Hung())
+}
+
+func TestHungMountDetectorTransport(t *testing.T) {
+  detector := nfsmountstats.NewHungMountDetector()
+  detector.WarnAfter = 10 * time.Second
+  detector.CriticalAfter = 30 * time.Second
+  start := time.Now()
+
+  prev := hungSnapshot(t, start, 0, sharedTransportMounts, 0, 0)
+  for step := 1; step <= 2; step++ {
+    cur := hungSnapshot(t, start, step, sharedTransportMounts, uint64(step*10), 0)
+    detector.Update(cur.Delta(prev))
+    prev = cur
+  }
+  cur := hungSnapshot(t, start, 3, sharedTransportMounts, 30, 0)
+  findings := detector.Update(cur.Delta(prev))
+
+  // every mount on the transport, and the transport itself
+  assert.Len(t, findings, 4)
+  var transport *nfsmountstats.Finding
+  for idx := range findings {
+    assert.Equal(t, nfsmountstats.SeverityCritical, findings[idx].Severity)
+    if findings[idx].ID == nfsmountstats.FindingHungTransport {
+      transport = &findings[idx]
+    }
+  }
+  if assert.NotNil(t, transport) {
+    assert.Equal(t, "tcp 10.0.47.9 port 840", transport.Transport)
+    assert.Equal(t, "", transport.Mountpoint)
+    assert.Contains(t, transport.Message, "/fakehomestatver1, /mailhome5, /mailhome6")
+  }
+}
+
+func TestHungMountDetectorRemount(t *testing.T) {
+  detector := nfsmountstats.NewHungMountDetector()
+  detector.WarnAfter = 10 * time.Second
+  start := time.Now()
+  mounts := []string{"/mailhome6"}
+
+  prev := hungSnapshot(t, start, 0, mounts, 0, 0)
+  cur := hungSnapshot(t, start, 1, mounts, 10, 0)
+  assert.Len(t, detector.Update(cur.Delta(prev)), 1)
+
+  // a fresh mount in its place isn't stuck
+  remounted := hungSnapshot(t, start, 2, mounts, 10, 0)
+  findDevice(t, remounted, "/mailhome6").NFSInfo.Age = 5
+  assert.Empty(t, detector.Update(remounted.Delta(cur)))
+  assert.Empty(t, detector.Hung())
+}
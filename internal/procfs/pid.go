@@ -0,0 +1,74 @@
+package procfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+const (
+  procPath = "/proc" // the root of procfs, every pid has a directory in here 
+)
+
+// ListPIDs returns the pid of every process directory found under `/proc`
+// (prefixed with PathPrefix), sorted ascending.
+// Returns non-nil error if the directory could not be read.
+func ListPIDs() ([]int, error) {
+  entries, err := os.ReadDir(filepath.Join(PathPrefix, procPath))
+  if err != nil {
+    return nil, fmt.Errorf("failed to list pids (%v)", err)
+  }
+
+  var pids []int
+  for _, entry := range entries {
+    // everything that isn't a number (self, sys, meminfo, ...) isn't a process 
+    pid, err := strconv.Atoi(entry.Name())
+    if err != nil || !entry.IsDir() {
+      continue
+    }
+    pids = append(pids, pid)
+  }
+  sort.Ints(pids)
+
+  return pids, nil
+}
+
+// ReadMountNamespace returns the mount namespace identifier of `pid`, which is 
+// the target of the `/proc/<pid>/ns/mnt` link, eg: `mnt:[4026531841]`.
+// Two pids are in the same mount namespace, and see the same mounts, when 
+// their identifiers are equal.
+// Returns non-nil error if the link could not be read, which is normal for 
+// processes that exited or that we don't have permission to inspect.
+func ReadMountNamespace(pid int) (string, error) {
+  ns, err := os.Readlink(GetPIDPath(pid, "ns/mnt"))
+  if err != nil {
+    return "", fmt.Errorf("failed to read mount namespace of pid %d (%v)", pid, err)
+  }
+
+  return ns, nil
+}
+
+// ReadCgroup reads the entire `/proc/<pid>/cgroup` file of `pid`.
+// Returns non-nil error if the file could not be read.
+func ReadCgroup(pid int) ([]byte, error) {
+  content, err := os.ReadFile(GetPIDPath(pid, "cgroup"))
+  if err != nil {
+    return nil, fmt.Errorf("failed to read cgroup of pid %d (%v)", pid, err)
+  }
+
+  return content, nil
+}
+
+// ReadPIDMountstatsConsistent is the same as ReadMountstatsConsistent but reads 
+// `/proc/<pid>/mountstats`, which shows the mounts of that pid's mount namespace.
+func ReadPIDMountstatsConsistent(pid int, maxRetries int) ([]byte, int, error) {
+  return readMountstatsConsistent(GetPIDPath(pid, "mountstats"), maxRetries)
+}
+
+// GetPIDPath returns the path of `name` inside the procfs directory of `pid`, 
+// prefixed with any package level PathPrefix.
+func GetPIDPath(pid int, name string) string {
+  return filepath.Join(PathPrefix, procPath, strconv.Itoa(pid), name)
+}
@@ -1,33 +1,188 @@
 package procfs
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
   mountstatsPath = "/proc/self/mountstats" // this path is where mountstats exist on on all linux 
+  uptimePath = "/proc/uptime" // seconds since boot, same clock as the mountstats age: field 
+  mountinfoPath = "/proc/self/mountinfo" // mount ids, device numbers and propagation of every mount 
+
+  DefaultReadRetries = 5 // default retry budget for ReadMountstatsConsistent
 )
 
+// ErrTornRead is returned when the mountstats content changed underneath us 
+// on every attempt within the retry budget, or was cut off mid-device.
+var ErrTornRead = errors.New("mountstats content changed or was truncated while reading")
+
 var PathPrefix = "" // path to prefix the mountstats constant path with, for testing purposes 
 
 // ReadMountstats opens the `/proc/self/mountstats` and reads it's
 // entire contents into a byte slice.
 // Returns non-nil error if the file could not be read. Never returns `io.EOF`.
 func ReadMountstats() ([]byte, error) {
-  path := GetMountstatsPath()
-  
+  return readMountstatsFile(GetMountstatsPath())
+} 
+
+// readMountstatsFile reads an entire mountstats file at `path`, which may 
+// be the one for self or for any other pid.
+func readMountstatsFile(path string) ([]byte, error) {
   content, err := os.ReadFile(path)
   if err != nil {
-    return nil, fmt.Errorf("failed to read mountstats file (%v)", err)
+    return nil, fmt.Errorf("failed to read mountstats file (%w)", err)
   }
 
   return content, nil
 } 
 
+// ReadMountstatsConsistent reads `/proc/self/mountstats` until it gets a copy
+// that can be trusted to not be torn. The kernel generates this file through a 
+// seq_file, so on hosts with many mounts (or lots of mount churn) the content can 
+// change between the chunks of a single read, leaving a device cut off mid-section 
+// or a mount set that never existed at any one point in time. 
+// A read is accepted when it passes CheckMountstats and lists exactly the same 
+// devices as the read immediately before it. Each failed attempt costs one retry, 
+// up to `maxRetries`.
+// Returns the content and the number of retries that were needed. Returns 
+// ErrTornRead (wrapped) if the budget was exhausted.
+func ReadMountstatsConsistent(maxRetries int) ([]byte, int, error) {
+  return readMountstatsConsistent(GetMountstatsPath(), maxRetries)
+}
+
+// readMountstatsConsistent implements ReadMountstatsConsistent for the 
+// mountstats file at `path`.
+func readMountstatsConsistent(path string, maxRetries int) ([]byte, int, error) {
+  retries := 0
+  var prev []byte
+
+  for {
+    content, err := readMountstatsFile(path)
+    if err != nil {
+      return nil, retries, err
+    }
+
+    err = CheckMountstats(content)
+    if err == nil && prev != nil && bytes.Equal(deviceLines(prev), deviceLines(content)) {
+      return content, retries, nil
+    }
+
+    // the very first read only establishes the baseline to compare against, 
+    // anything else is a torn or inconsistent read and uses up a retry 
+    if prev != nil || err != nil {
+      if retries >= maxRetries {
+        if err == nil {
+          err = errors.New("mount set changed between reads")
+        }
+        return nil, retries, fmt.Errorf("%w after %d retries (%v)", ErrTornRead, retries, err)
+      }
+      retries++
+    }
+
+    if err != nil {
+      prev = nil
+    } else {
+      prev = content
+    }
+  }
+}
+
+// CheckMountstats does a structural sanity check on mountstats content, looking 
+// for the signs of a torn read. It does not parse any counters. 
+// Returns non-nil error if the content is empty, doesn't end in a newline, or 
+// if any device with a `statvers=` (NFS) is missing the sections that the kernel 
+// always writes for it, which happens when a read is cut mid-device.
+func CheckMountstats(content []byte) error {
+  if len(content) == 0 {
+    return errors.New("empty mountstats content")
+  }
+  if !bytes.HasPrefix(content, []byte("device ")) {
+    return errors.New("mountstats content does not begin with a device")
+  }
+  if content[len(content)-1] != '\n' {
+    return errors.New("mountstats content ends mid-line")
+  }
+
+  sections := bytes.Split(content, []byte("\ndevice "))
+  for idx, section := range sections {
+    header, _, _ := bytes.Cut(section, []byte("\n"))
+    if !bytes.Contains(header, []byte("statvers=")) {
+      continue
+    }
+    // every nfs device with stats gets these, with the per-op 
+    // table being the last thing written and followed by an empty line 
+    for _, label := range []string{"age:", "events:", "bytes:", "xprt:", "per-op statistics"} {
+      if !bytes.Contains(section, []byte(label)) {
+        return fmt.Errorf("device %d is missing `%s`, truncated section", idx, label)
+      }
+    }
+    // the per-op table is followed by an empty line, which we can only see on 
+    // the last device since the split ate the newline before any later device 
+    if idx == len(sections)-1 && !bytes.HasSuffix(section, []byte("\n\n")) {
+      return fmt.Errorf("device %d per-op statistics were cut off", idx)
+    }
+  }
+
+  return nil
+}
+
+// deviceLines returns just the `device ...` header lines from content, which 
+// is enough to tell whether two reads saw the same set of mounts.
+func deviceLines(content []byte) []byte {
+  var out []byte
+  for _, line := range bytes.Split(content, []byte("\n")) {
+    if bytes.HasPrefix(line, []byte("device ")) {
+      out = append(out, line...)
+      out = append(out, '\n')
+    }
+  }
+
+  return out
+}
+
 // GetMountstatsPath returns the mountstats path appended with any 
 // package level prefix that was set with PathPrefix
 func GetMountstatsPath() (string) {
   return filepath.Join(PathPrefix,mountstatsPath)
 }
+
+// ReadUptime reads `/proc/uptime` (prefixed with PathPrefix) and returns how 
+// long the kernel has been up, which is the clock mountstats ages are kept in.
+// Returns non-nil error if the file could not be read or parsed.
+func ReadUptime() (time.Duration, error) {
+  content, err := os.ReadFile(filepath.Join(PathPrefix, uptimePath))
+  if err != nil {
+    return 0, fmt.Errorf("failed to read uptime file (%v)", err)
+  }
+
+  // the first field is the uptime in seconds, the second is idle time 
+  fields := strings.Fields(string(content))
+  if len(fields) < 1 {
+    return 0, fmt.Errorf("empty uptime file")
+  }
+  seconds, err := strconv.ParseFloat(fields[0], 64)
+  if err != nil {
+    return 0, fmt.Errorf("failed to parse uptime: %v (%v)", fields[0], err)
+  }
+
+  return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ReadMountinfo reads the entire `/proc/self/mountinfo` file (prefixed with 
+// PathPrefix), which carries the device number of every mount's superblock.
+// Returns non-nil error if the file could not be read.
+func ReadMountinfo() ([]byte, error) {
+  content, err := os.ReadFile(filepath.Join(PathPrefix, mountinfoPath))
+  if err != nil {
+    return nil, fmt.Errorf("failed to read mountinfo file (%v)", err)
+  }
+
+  return content, nil
+}
@@ -1,8 +1,11 @@
 package procfs_test
 
 import (
+	"errors"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	// "github.com/davecgh/go-spew/spew"
 	"github.com/jessegalley/nfsmountstats/internal/procfs"
@@ -35,3 +38,74 @@ func TestReadMountstatsFile(t *testing.T) {
   assert.Less(t, 10, len(strings.Split(contentStr, "\n")))
   // TODO: add more robust tests here, though i'm not yet sure what...
 }
+
+// TestReadMountstatsConsistent reads the example mountstats file, which doesn't 
+// change between reads, so it should be accepted without any retries.
+func TestReadMountstatsConsistent(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  content, retries, err := procfs.ReadMountstatsConsistent(procfs.DefaultReadRetries)
+  if err != nil {
+    t.Fatalf("failed to read mountstats consistently: %v", err)
+  }
+
+  assert.Equal(t, 0, retries)
+  assert.Equal(t, "device", string(content[:6]))
+}
+
+// TestReadMountstatsConsistentTorn reads a mountstats file which was cut off in 
+// the middle of the per-op stats of an NFS device, every attempt should be 
+// rejected until the retry budget runs out.
+func TestReadMountstatsConsistentTorn(t *testing.T) {
+  procfs.PathPrefix = "testdata/torn"
+  _, retries, err := procfs.ReadMountstatsConsistent(3)
+  if err == nil {
+    t.Fatalf("expected torn read to fail")
+  }
+
+  assert.True(t, errors.Is(err, procfs.ErrTornRead))
+  assert.Equal(t, 3, retries)
+}
+
+func TestCheckMountstats(t *testing.T) {
+  content, err := os.ReadFile("testdata/proc/self/mountstats")
+  if err != nil {
+    t.Fatalf("failed to read testdata: %v", err)
+  }
+  assert.NoError(t, procfs.CheckMountstats(content))
+
+  // cut the content at a few places that a torn read could leave us with 
+  text := string(content)
+  assert.Error(t, procfs.CheckMountstats(nil))
+  assert.Error(t, procfs.CheckMountstats(content[:len(content)-5]))
+  cut := strings.Index(text, "\tevents:")
+  assert.Error(t, procfs.CheckMountstats([]byte(text[:cut])))
+  cut = strings.Index(text, "GETATTR:")
+  cut = strings.LastIndex(text[:cut], "\n") + 1
+  assert.Error(t, procfs.CheckMountstats([]byte(text[:cut])))
+  // a device list without any nfs sections is fine
+  assert.NoError(t, procfs.CheckMountstats([]byte("device proc mounted on /proc with fstype proc\n")))
+}
+
+func TestReadUptime(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  uptime, err := procfs.ReadUptime()
+  if err != nil {
+    t.Fatalf("failed to read uptime: %v", err)
+  }
+
+  assert.Equal(t, 2919220470*time.Millisecond, uptime)
+}
+
+func TestReadMountinfo(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  content, err := procfs.ReadMountinfo()
+  if err != nil {
+    t.Fatalf("failed to read mountinfo: %v", err)
+  }
+
+  assert.True(t, strings.HasPrefix(string(content), "22 29 0:21 / /sys "))
+
+  procfs.PathPrefix = "testdata/nonexistent"
+  _, err = procfs.ReadMountinfo()
+  assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package nfsmountstats
+
+import (
+	"sort"
+	"time"
+)
+
+// MountIOStats is the nfsiostat view of a single NFS mount, either since it
+// was mounted or over an interval between two snapshots.
+type MountIOStats struct {
+  Device     string
+  Mountpoint string
+  SampleTime time.Duration        // the time the stats are averaged over
+  OpsPerSec  float64              // rpc requests sent per second, all ops
+  RPCBacklog float64              // average rpc backlog queue length
+  Ops        map[string]OpIOStats // per op stats, keyed on op name
+}
+
+// OpIOStats is the nfsiostat view of a single op. All of the times are in
+// milliseconds, averaged per operation.
+type OpIOStats struct {
+  Op             string
+  Operations     uint64
+  OpsPerSec      float64
+  KBPerSec       float64 // kilobytes sent and received on the wire per second
+  KBPerOp        float64
+  Retrans        uint64  // transmissions beyond the first for each op
+  RetransPercent float64
+  AvgRTT         float64 // average round trip time
+  AvgExe         float64 // average execute time, from queueing to completion
+  AvgQueue       float64 // average time queued before transmission
+  Errors         uint64  // ops that completed with an error, statvers 1.1+
+  ErrorsPercent  float64
+}
+
+// IOStats computes the nfsiostat stats of the device since it was mounted,
+// averaging over its Age just like nfsiostat does without an interval.
+func (d *MountDevice) IOStats() MountIOStats {
+  return newMountIOStats(d.Device, d.Mountpoint, &d.NFSInfo, time.Duration(d.NFSInfo.Age)*time.Second)
+}
+
+// IOStats computes the nfsiostat stats of the device over the Interval of the
+// delta, which is what nfsiostat prints for every interval after the first.
+func (d *MountDeviceDelta) IOStats() MountIOStats {
+  return newMountIOStats(d.Device, d.Mountpoint, &d.NFSInfo, d.Interval)
+}
+
+// SortedOps returns the op stats sorted by name, which is handy for stable
+// output.
+func (s MountIOStats) SortedOps() []OpIOStats {
+  ops := make([]OpIOStats, 0, len(s.Ops))
+  for _, op := range s.Ops {
+    ops = append(ops, op)
+  }
+  sort.Slice(ops, func(i, j int) bool {
+    return ops[i].Op < ops[j].Op
+  })
+
+  return ops
+}
+
+// newMountIOStats does the nfsiostat arithmetic over the counters in `info`,
+// which are either cumulative or a delta, spread over `sample`.
+func newMountIOStats(device string, mountpoint string, info *NFSInfo, sample time.Duration) MountIOStats {
+  // nfsiostat falls back to one second when there's no time to average over,
+  // eg: for a mount that's less than a second old, so the counters still show
+  seconds := sample.Seconds()
+  if seconds <= 0 {
+    seconds = 1
+  }
+
+  stats := MountIOStats{
+    Device: device,
+    Mountpoint: mountpoint,
+    SampleTime: sample,
+    Ops: make(map[string]OpIOStats, len(info.RPCOpStats)),
+  }
+
+  sends, backlog := transportSendsAndBacklog(info.Transport)
+  stats.OpsPerSec = float64(sends) / seconds
+  if sends > 0 {
+    // this is how nfsiostat computes it, even though the division by the
+    // sample time makes it more of a rate than a queue length
+    stats.RPCBacklog = (float64(backlog) / float64(sends)) / seconds
+  }
+
+  for op, s := range info.RPCOpStats {
+    stats.Ops[op] = newOpIOStats(op, s, seconds)
+  }
+
+  return stats
+}
+
+// newOpIOStats does the nfsiostat arithmetic for a single op over `seconds`.
+func newOpIOStats(op string, s RPCOpStat, seconds float64) OpIOStats {
+  stats := OpIOStats{
+    Op: op,
+    Operations: s.Operations,
+    Errors: s.ErrStats,
+  }
+  if s.Transmissions > s.Operations {
+    stats.Retrans = s.Transmissions - s.Operations
+  }
+
+  kilobytes := float64(s.BytesSent+s.BytesReceived) / 1024
+  stats.OpsPerSec = float64(s.Operations) / seconds
+  stats.KBPerSec = kilobytes / seconds
+
+  if s.Operations > 0 {
+    ops := float64(s.Operations)
+    stats.KBPerOp = kilobytes / ops
+    stats.RetransPercent = float64(stats.Retrans) * 100 / ops
+    stats.AvgRTT = float64(s.CumRespTime) / ops
+    stats.AvgExe = float64(s.CumTotalReqTime) / ops
+    stats.AvgQueue = float64(s.CumQueueTime) / ops
+    stats.ErrorsPercent = float64(s.ErrStats) * 100 / ops
+  }
+
+  return stats
+}
+
+// transportSendsAndBacklog returns the rpc sends and cumulative backlog of any
+// of the transport types, 0s if there is no transport.
+func transportSendsAndBacklog(t NFSTransportCounters) (uint64, uint64) {
+  switch t := t.(type) {
+  case *NFSTransportCountersUDP:
+    return t.RpcSends, t.BacklogUtil
+  case *NFSTransportCountersTCP:
+    return t.RpcSends, t.BacklogUtil
+  case *NFSTransportCountersRDMA:
+    return t.RpcSends, t.BacklogUtil
+  }
+
+  return 0, 0
+}
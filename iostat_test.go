@@ -0,0 +1,81 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIOStatsSinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // READ: 6438446 6438446 0 875628656 94672388276 470744 23447336 24093392
+  // age: 2919118
+  stats := findDevice(t, mounts, "/mailhome6").IOStats()
+  assert.Equal(t, 2919118*time.Second, stats.SampleTime)
+  assert.InDelta(t, 347.2677, stats.OpsPerSec, 0.0001)
+  assert.InDelta(t, 0.0, stats.RPCBacklog, 0.0001)
+
+  read := stats.Ops["READ"]
+  assert.Equal(t, uint64(6438446), read.Operations)
+  assert.InDelta(t, 2.2056, read.OpsPerSec, 0.0001)
+  assert.InDelta(t, 31.9647, read.KBPerSec, 0.0001)
+  assert.InDelta(t, 14.4924, read.KBPerOp, 0.0001)
+  assert.Equal(t, uint64(0), read.Retrans)
+  assert.InDelta(t, 3.6418, read.AvgRTT, 0.0001)
+  assert.InDelta(t, 3.7421, read.AvgExe, 0.0001)
+  assert.InDelta(t, 0.0731, read.AvgQueue, 0.0001)
+
+  // GETATTR: 13920 13924 0 3187904 3394844 6668 27030 34563 7
+  getattr := findDevice(t, mounts, "/mnt/nfs1/docs").IOStats().Ops["GETATTR"]
+  assert.Equal(t, uint64(4), getattr.Retrans)
+  assert.InDelta(t, 0.0287, getattr.RetransPercent, 0.0001)
+  assert.Equal(t, uint64(7), getattr.Errors)
+  assert.InDelta(t, 0.0503, getattr.ErrorsPercent, 0.0001)
+  assert.InDelta(t, 0.4790, getattr.AvgQueue, 0.0001)
+
+  // ops that never ran are all zeros rather than NaN
+  webmailWrite := findDevice(t, mounts, "/webmail0").IOStats().Ops["WRITE"]
+  assert.Equal(t, 0.0, webmailWrite.AvgRTT)
+  assert.Equal(t, 0.0, webmailWrite.KBPerOp)
+}
+
+func TestIOStatsInterval(t *testing.T) {
+  delta := loadTestDelta(t, 5*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 5
+    write := dev.NFSInfo.RPCOpStats["WRITE"]
+    write.Operations += 100
+    write.Transmissions += 102
+    write.BytesSent += 100 * 16384
+    write.BytesReceived += 100 * 160
+    write.CumRespTime += 400
+    write.CumTotalReqTime += 900
+    write.CumQueueTime += 450
+    dev.NFSInfo.RPCOpStats["WRITE"] = write
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 102
+    xprt.BacklogUtil += 51
+  })
+
+  stats := delta.GetMountMap()["/mailhome6"].IOStats()
+  assert.Equal(t, 5*time.Second, stats.SampleTime)
+  assert.InDelta(t, 20.4, stats.OpsPerSec, 0.0001)
+  assert.InDelta(t, 0.1, stats.RPCBacklog, 0.0001)
+
+  w := stats.Ops["WRITE"]
+  assert.InDelta(t, 20.0, w.OpsPerSec, 0.0001)
+  assert.InDelta(t, 100*(16384+160)/1024.0/5, w.KBPerSec, 0.0001)
+  assert.InDelta(t, (16384+160)/1024.0, w.KBPerOp, 0.0001)
+  assert.Equal(t, uint64(2), w.Retrans)
+  assert.InDelta(t, 2.0, w.RetransPercent, 0.0001)
+  assert.InDelta(t, 4.0, w.AvgRTT, 0.0001)
+  assert.InDelta(t, 9.0, w.AvgExe, 0.0001)
+  assert.InDelta(t, 4.5, w.AvgQueue, 0.0001)
+  assert.Equal(t, 0.0, stats.Ops["READ"].OpsPerSec)
+
+  ops := stats.SortedOps()
+  assert.Equal(t, "ACCESS", ops[0].Op)
+}
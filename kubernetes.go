@@ -0,0 +1,172 @@
+package nfsmountstats
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+)
+
+// KubeletRootDirs is the list of kubelet root directories that mountpoints are
+// matched against when resolving Kubernetes volumes. The default covers a stock
+// kubelet, k0s and microk8s, add to it if the kubelet runs with `--root-dir`.
+var KubeletRootDirs = []string{
+  "/var/lib/kubelet",
+  "/var/lib/k0s/kubelet",
+  "/var/snap/microk8s/common/var/lib/kubelet",
+}
+
+// kinds of kubelet managed mounts
+const (
+  KubernetesVolumeKindPod     = "pod"     // a volume mounted for a single pod
+  KubernetesVolumeKindSubpath = "subpath" // a subPath bind of a pod volume into a container
+  KubernetesVolumeKindStaging = "staging" // a node global CSI staging mount, shared by pods
+)
+
+// the in-tree plugin name for CSI, any volume using it may be backed by any driver
+const csiPluginName = "kubernetes.io/csi"
+
+// KubernetesVolume is the metadata that can be decoded from the path of a kubelet
+// managed mountpoint, without asking the API server.
+type KubernetesVolume struct {
+  Kind       string // one of the KubernetesVolumeKind* constants
+  PodUID     string // uid of the pod, empty for staging mounts
+  VolumeName string // the directory name kubelet used for the volume
+  PVName     string // the PersistentVolume name, empty for inline volumes and when it can't be told
+  Driver     string // volume plugin or CSI driver name, eg: `kubernetes.io/nfs`, see ParseKubeletPath
+  Container  string // container name, only for subpath mounts
+}
+
+// ParseKubeletPath decodes a mountpoint that follows one of the kubelet mount
+// path conventions under any of the KubeletRootDirs:
+//
+//   <root>/pods/<uid>/volumes/<plugin>/<volume>            (in-tree, eg: kubernetes.io~nfs)
+//   <root>/pods/<uid>/volumes/kubernetes.io~csi/<volume>/mount
+//   <root>/pods/<uid>/volume-subpaths/<volume>/<container>/<index>
+//   <root>/plugins/kubernetes.io/csi/<driver>/<hash>/globalmount
+//   <root>/plugins/kubernetes.io/csi/pv/<pv>/globalmount   (older kubelets)
+//
+// Kubelet names a pod volume directory after the PV for PersistentVolume backed
+// volumes, and after the volume in the pod spec for inline ones. CSI pod volumes
+// are nearly always PV backed, so PVName is taken from their directory, unless
+// kubelet's `vol_data.json` next to the mount says the volume is an inline
+// ephemeral one. The same file gives the real CSI driver name, without it
+// Driver is the generic `kubernetes.io/csi` plugin. In-tree plugins keep no
+// such file, so their PVName is left empty rather than naming a PV that may
+// not exist. The older staging path is always named after the PV.
+// Returns false if the path isn't a kubelet managed mountpoint.
+func ParseKubeletPath(mountpoint string) (*KubernetesVolume, bool) {
+  mountpoint = path.Clean(mountpoint)
+
+  for _, root := range KubeletRootDirs {
+    rel, ok := strings.CutPrefix(mountpoint, root+"/")
+    if !ok {
+      continue
+    }
+
+    elements := strings.Split(rel, "/")
+    switch elements[0] {
+    case "pods":
+      vol, ok := parseKubeletPodPath(elements[1:])
+      if ok && vol.Driver == csiPluginName {
+        vol.readCSIVolumeData(mountpoint)
+      }
+      return vol, ok
+    case "plugins":
+      return parseKubeletPluginPath(elements[1:])
+    }
+  }
+
+  return nil, false
+}
+
+// parseKubeletPodPath decodes the path elements that follow `<root>/pods/`.
+func parseKubeletPodPath(elements []string) (*KubernetesVolume, bool) {
+  if len(elements) < 4 {
+    return nil, false
+  }
+
+  vol := KubernetesVolume{PodUID: elements[0]}
+  switch elements[1] {
+  case "volumes":
+    // plugin names have their slash escaped as a tilde on disk
+    vol.Kind = KubernetesVolumeKindPod
+    vol.Driver = strings.ReplaceAll(elements[2], "~", "/")
+    vol.VolumeName = elements[3]
+    // csi volumes are mounted one level deeper, on the `mount` dir
+    if vol.Driver == csiPluginName && (len(elements) != 5 || elements[4] != "mount") {
+      return nil, false
+    }
+    if vol.Driver != csiPluginName && len(elements) != 4 {
+      return nil, false
+    }
+    if vol.Driver == csiPluginName {
+      vol.PVName = vol.VolumeName
+    }
+  case "volume-subpaths":
+    if len(elements) != 5 {
+      return nil, false
+    }
+    vol.Kind = KubernetesVolumeKindSubpath
+    vol.VolumeName = elements[2]
+    vol.Container = elements[3]
+  default:
+    return nil, false
+  }
+
+  return &vol, true
+}
+
+// csiVolumeData is the part of the `vol_data.json` kubelet keeps next to the
+// mount of every CSI pod volume that says what the volume is.
+type csiVolumeData struct {
+  DriverName          string `json:"driverName"`
+  SpecVolID           string `json:"specVolID"`
+  VolumeLifecycleMode string `json:"volumeLifecycleMode"`
+}
+
+// readCSIVolumeData fills in the driver and PV name of a CSI pod volume mounted
+// on `mountpoint` from kubelet's `vol_data.json`. The file is only there on the
+// node itself, and when it can't be read the path is all there is to go on.
+func (v *KubernetesVolume) readCSIVolumeData(mountpoint string) {
+  content, err := os.ReadFile(path.Join(path.Dir(mountpoint), "vol_data.json"))
+  if err != nil {
+    return
+  }
+  var data csiVolumeData
+  if err := json.Unmarshal(content, &data); err != nil {
+    return
+  }
+
+  if data.DriverName != "" {
+    v.Driver = data.DriverName
+  }
+  if data.SpecVolID != "" {
+    v.PVName = data.SpecVolID
+  }
+  if data.VolumeLifecycleMode == "Ephemeral" {
+    v.PVName = ""
+  }
+}
+
+// parseKubeletPluginPath decodes the path elements that follow `<root>/plugins/`.
+// Only CSI staging mounts are understood.
+func parseKubeletPluginPath(elements []string) (*KubernetesVolume, bool) {
+  if len(elements) != 5 || elements[0] != "kubernetes.io" || elements[1] != "csi" || elements[4] != "globalmount" {
+    return nil, false
+  }
+
+  vol := KubernetesVolume{Kind: KubernetesVolumeKindStaging}
+  if elements[2] == "pv" {
+    // older kubelets stage by pv name and don't record the driver in the path
+    vol.PVName = elements[3]
+    vol.VolumeName = elements[3]
+    vol.Driver = csiPluginName
+  } else {
+    // newer kubelets stage under the driver name and a hash of the volume handle
+    vol.Driver = elements[2]
+    vol.VolumeName = elements[3]
+  }
+
+  return &vol, true
+}
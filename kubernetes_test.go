@@ -0,0 +1,94 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKubeletPath(t *testing.T) {
+  // in-tree nfs volume 
+  vol, ok := nfsmountstats.ParseKubeletPath("/var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volumes/kubernetes.io~nfs/mail-pv")
+  assert.True(t, ok)
+  assert.Equal(t, nfsmountstats.KubernetesVolumeKindPod, vol.Kind)
+  assert.Equal(t, "6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69", vol.PodUID)
+  assert.Equal(t, "mail-pv", vol.VolumeName)
+  // the volume may be inline, in which case there's no pv by that name
+  assert.Equal(t, "", vol.PVName)
+  assert.Equal(t, "kubernetes.io/nfs", vol.Driver)
+
+  // csi volume published into a pod 
+  vol, ok = nfsmountstats.ParseKubeletPath("/var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volumes/kubernetes.io~csi/pvc-0f1e2d3c/mount")
+  assert.True(t, ok)
+  assert.Equal(t, "pvc-0f1e2d3c", vol.VolumeName)
+  assert.Equal(t, "pvc-0f1e2d3c", vol.PVName)
+  // there's no vol_data.json to say which driver it is
+  assert.Equal(t, "kubernetes.io/csi", vol.Driver)
+
+  // csi staging mounts, new and old layouts 
+  vol, ok = nfsmountstats.ParseKubeletPath("/var/lib/kubelet/plugins/kubernetes.io/csi/nfs.csi.k8s.io/4f3c2b1a0e9d/globalmount")
+  assert.True(t, ok)
+  assert.Equal(t, nfsmountstats.KubernetesVolumeKindStaging, vol.Kind)
+  assert.Equal(t, "nfs.csi.k8s.io", vol.Driver)
+  assert.Equal(t, "", vol.PodUID)
+
+  vol, ok = nfsmountstats.ParseKubeletPath("/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pvc-0f1e2d3c/globalmount")
+  assert.True(t, ok)
+  assert.Equal(t, "pvc-0f1e2d3c", vol.PVName)
+
+  // subpath bind into a container 
+  vol, ok = nfsmountstats.ParseKubeletPath("/var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volume-subpaths/mail-pv/dovecot/0")
+  assert.True(t, ok)
+  assert.Equal(t, nfsmountstats.KubernetesVolumeKindSubpath, vol.Kind)
+  assert.Equal(t, "dovecot", vol.Container)
+
+  // things that aren't kubelet mounts, or are just kubelet directories 
+  _, ok = nfsmountstats.ParseKubeletPath("/mnt/nfs1/docs")
+  assert.False(t, ok)
+  _, ok = nfsmountstats.ParseKubeletPath("/var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volumes")
+  assert.False(t, ok)
+  _, ok = nfsmountstats.ParseKubeletPath("/var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volumes/kubernetes.io~csi/pvc-0f1e2d3c")
+  assert.False(t, ok)
+}
+
+func TestParseKubeletPathVolumeData(t *testing.T) {
+  roots := nfsmountstats.KubeletRootDirs
+  defer func() { nfsmountstats.KubeletRootDirs = roots }()
+  nfsmountstats.KubeletRootDirs = append(roots, "testdata/var/lib/kubelet")
+  pod := "testdata/var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volumes/kubernetes.io~csi/"
+
+  // vol_data.json has the real driver
+  vol, ok := nfsmountstats.ParseKubeletPath(pod + "pvc-0f1e2d3c/mount")
+  assert.True(t, ok)
+  assert.Equal(t, "pvc-0f1e2d3c", vol.PVName)
+  assert.Equal(t, "nfs.csi.k8s.io", vol.Driver)
+
+  // an inline ephemeral volume has no pv
+  vol, ok = nfsmountstats.ParseKubeletPath(pod + "scratch/mount")
+  assert.True(t, ok)
+  assert.Equal(t, "scratch", vol.VolumeName)
+  assert.Equal(t, "", vol.PVName)
+  assert.Equal(t, "nfs.csi.k8s.io", vol.Driver)
+}
+
+func TestParseDeviceKubernetes(t *testing.T) {
+  exampleDeviceText := `device 10.0.2.31:/volume1/k8s mounted on /var/lib/kubelet/pods/6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69/volumes/kubernetes.io~nfs/mail-pv with fstype nfs4 statvers=1.1`
+
+  mount := nfsmountstats.MountDevice{}
+  err := mount.Parse(exampleDeviceText)
+  if err != nil {
+    t.Errorf("couldn't parse test device string: %v", err)
+  }
+
+  if assert.NotNil(t, mount.Kubernetes) {
+    assert.Equal(t, "6b1f0c3e-2a4d-4c8e-9f7a-1d2e3c4b5a69", mount.Kubernetes.PodUID)
+    assert.Equal(t, "mail-pv", mount.Kubernetes.VolumeName)
+    assert.Equal(t, "", mount.Kubernetes.PVName)
+  }
+
+  // regular mounts shouldn't get any kubernetes metadata 
+  err = mount.Parse(`device 10.0.2.31:/volume1/Public/docs mounted on /mnt/nfs1/docs with fstype nfs4 statvers=1.1`)
+  assert.NoError(t, err)
+  assert.Nil(t, mount.Kubernetes)
+}
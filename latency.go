@@ -0,0 +1,90 @@
+package nfsmountstats
+
+// Bottleneck is where most of the time of an op goes.
+type Bottleneck string
+
+const (
+  BottleneckNone   Bottleneck = "none"   // nothing ran
+  BottleneckClient Bottleneck = "client" // waiting on the client for a slot or the transport, a client backlog
+  BottleneckServer Bottleneck = "server" // waiting for the reply, a slow server or network
+  BottleneckKernel Bottleneck = "kernel" // neither, time spent in the client's rpc code after the reply
+)
+
+// LatencyBreakdown splits the time an op takes, from being queued to being
+// done, into the time queued on the client (CumQueueTime), the round trip to
+// the server (CumRespTime) and whatever is left (kernel overhead, mostly
+// processing the reply). Times are average milliseconds per op, and shares are
+// ratios of the total time between 0 and 1.
+// The bottleneck is wherever most of the time goes, it says nothing about
+// whether the op is slow, a fast server is still the bottleneck of a healthy
+// mount.
+type LatencyBreakdown struct {
+  Op            string // empty for the mount as a whole
+  Operations    uint64
+  AvgQueue      float64
+  AvgRTT        float64
+  AvgOverhead   float64
+  AvgTotal      float64
+  QueueShare    float64
+  RTTShare      float64
+  OverheadShare float64
+  Bottleneck    Bottleneck
+}
+
+// MountLatency is the LatencyBreakdown of a mount and of each of its ops.
+type MountLatency struct {
+  Total LatencyBreakdown
+  Ops   []LatencyBreakdown // ops that ran, in the order the kernel lists them
+}
+
+// LatencyBreakdown breaks down the latency of every op, and of all of them
+// together. Works the same since mount and over a MountDeviceDelta, as its
+// NFSInfo holds the difference of the counters.
+func (i *NFSInfo) LatencyBreakdown() MountLatency {
+  var latency MountLatency
+  var total RPCOpStat
+  for _, op := range i.OpNames() {
+    s := i.RPCOpStats[op]
+    if s.Operations == 0 {
+      continue
+    }
+    total = total.add(s)
+    latency.Ops = append(latency.Ops, newLatencyBreakdown(op, s))
+  }
+  latency.Total = newLatencyBreakdown("", total)
+
+  return latency
+}
+
+// newLatencyBreakdown breaks down the cumulative times of `s`.
+func newLatencyBreakdown(op string, s RPCOpStat) LatencyBreakdown {
+  // the three times are measured separately and can be a little off, so
+  // the queue and round trip may add up to more than the total
+  overhead := clampedSub(s.CumTotalReqTime, s.CumQueueTime+s.CumRespTime)
+  total := s.CumQueueTime + s.CumRespTime + overhead
+
+  b := LatencyBreakdown{
+    Op: op,
+    Operations: s.Operations,
+    AvgQueue: ratio(s.CumQueueTime, s.Operations),
+    AvgRTT: ratio(s.CumRespTime, s.Operations),
+    AvgOverhead: ratio(overhead, s.Operations),
+    AvgTotal: ratio(total, s.Operations),
+    QueueShare: ratio(s.CumQueueTime, total),
+    RTTShare: ratio(s.CumRespTime, total),
+    OverheadShare: ratio(overhead, total),
+    Bottleneck: BottleneckNone,
+  }
+
+  switch {
+  case s.Operations == 0 || total == 0:
+  case b.QueueShare > b.RTTShare && b.QueueShare >= b.OverheadShare:
+    b.Bottleneck = BottleneckClient
+  case b.OverheadShare > b.RTTShare:
+    b.Bottleneck = BottleneckKernel
+  default:
+    b.Bottleneck = BottleneckServer
+  }
+
+  return b
+}
@@ -0,0 +1,60 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyBreakdown(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  latency := findDevice(t, mounts, "/mailhome6").NFSInfo.LatencyBreakdown()
+  assert.Equal(t, "", latency.Total.Op)
+  assert.Equal(t, uint64(45093115), latency.Total.Operations)
+  assert.InDelta(t, 0.255430, latency.Total.AvgQueue, 0.000001)
+  assert.InDelta(t, 1.831107, latency.Total.AvgRTT, 0.000001)
+  assert.InDelta(t, 0.025613, latency.Total.AvgOverhead, 0.000001)
+  assert.InDelta(t, 95243366.0/45093115.0, latency.Total.AvgTotal, 0.000001)
+  assert.InDelta(t, 0.866940, latency.Total.RTTShare, 0.000001)
+  assert.Equal(t, nfsmountstats.BottleneckServer, latency.Total.Bottleneck)
+
+  // ops that never ran (NULL, MKNOD, LINK, COMMIT) are left out
+  assert.Len(t, latency.Ops, 18)
+  assert.Equal(t, "GETATTR", latency.Ops[0].Op)
+
+  // WRITE: 573159 573159 0 7704966112 91705440 10926178 7676187 18630731
+  var write nfsmountstats.LatencyBreakdown
+  for _, op := range latency.Ops {
+    if op.Op == "WRITE" {
+      write = op
+    }
+  }
+  assert.InDelta(t, 10926178.0/573159.0, write.AvgQueue, 0.000001)
+  assert.InDelta(t, 28366.0/573159.0, write.AvgOverhead, 0.000001)
+  assert.InDelta(t, 10926178.0/18630731.0, write.QueueShare, 0.000001)
+  assert.Equal(t, nfsmountstats.BottleneckClient, write.Bottleneck)
+}
+
+func TestLatencyBreakdownEdges(t *testing.T) {
+  info := nfsmountstats.NFSInfo{RPCOpStats: map[string]nfsmountstats.RPCOpStat{
+    // the queue and round trip add up to more than the total
+    "READ": {Operations: 10, CumQueueTime: 20, CumRespTime: 90, CumTotalReqTime: 100},
+    "WRITE": {Operations: 10, CumQueueTime: 5, CumRespTime: 10, CumTotalReqTime: 100},
+    "COMMIT": {},
+  }}
+
+  latency := info.LatencyBreakdown()
+  assert.Len(t, latency.Ops, 2)
+  read, write := latency.Ops[0], latency.Ops[1]
+  assert.Equal(t, "READ", read.Op)
+  assert.Equal(t, 0.0, read.AvgOverhead)
+  assert.InDelta(t, 11.0, read.AvgTotal, 0.000001)
+  assert.InDelta(t, 1.0, read.QueueShare+read.RTTShare, 0.000001)
+  assert.Equal(t, nfsmountstats.BottleneckServer, read.Bottleneck)
+  assert.Equal(t, nfsmountstats.BottleneckKernel, write.Bottleneck)
+
+  idle := nfsmountstats.NFSInfo{}
+  assert.Equal(t, nfsmountstats.BottleneckNone, idle.LatencyBreakdown().Total.Bottleneck)
+}
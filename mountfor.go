@@ -0,0 +1,139 @@
+package nfsmountstats
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+  maxSymlinks = 40 // same limit the kernel uses for path resolution (MAXSYMLINKS)
+)
+
+// MountFor finds the device that serves `path`, which may be relative and may
+// contain symlinks. The path is made absolute and its symlinks are resolved, then
+// it's matched against every mountpoint by longest prefix on path element
+// boundaries, so a mount nested inside another one wins over its parent. When the
+// same mountpoint is mounted more than once the last (topmost) mount wins, which is
+// the one the kernel would use.
+// The returned device is not necessarily NFS, check MountType if that matters.
+// Note that resolving symlinks needs an lstat of every path element, which will
+// block if an element lives on a hung hard mounted NFS server.
+// Returns an error if the path couldn't be resolved or no mount matched it.
+func (m *Mountstats) MountFor(path string) (*MountDevice, error) {
+  resolved, err := resolvePath(path)
+  if err != nil {
+    return nil, err
+  }
+
+  var match *MountDevice
+  matchLen := -1
+  for idx := range m.Devices {
+    mountpoint := unescapeMountpoint(m.Devices[idx].Mountpoint)
+    if !pathHasPrefix(resolved, mountpoint) {
+      continue
+    }
+    // >= so that later mounts stacked on the same mountpoint win
+    if len(mountpoint) >= matchLen {
+      match = &m.Devices[idx]
+      matchLen = len(mountpoint)
+    }
+  }
+
+  if match == nil {
+    return nil, fmt.Errorf("no mount found for path: %v", resolved)
+  }
+
+  return match, nil
+}
+
+// pathHasPrefix reports whether `prefix` is `path` or one of its parent
+// directories, comparing whole path elements only.
+func pathHasPrefix(path, prefix string) bool {
+  if prefix == "/" {
+    return strings.HasPrefix(path, "/")
+  }
+  if !strings.HasPrefix(path, prefix) {
+    return false
+  }
+
+  return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+// resolvePath makes `path` absolute and resolves every symlink in it. Unlike
+// filepath.EvalSymlinks this doesn't fail when part of the path doesn't exist,
+// the remainder is just taken as is, so dangling links into unmounted or
+// unreachable directories still resolve to where they point.
+// Returns an error if the working directory is unknown or there's a symlink loop.
+func resolvePath(path string) (string, error) {
+  abs, err := filepath.Abs(path)
+  if err != nil {
+    return "", fmt.Errorf("couldn't make path absolute: %v", err)
+  }
+
+  resolved := "/"
+  rest := strings.Split(abs, "/")
+  links := 0
+  for len(rest) > 0 {
+    elem := rest[0]
+    rest = rest[1:]
+
+    switch elem {
+    case "", ".":
+      continue
+    case "..":
+      resolved = filepath.Dir(resolved)
+      continue
+    }
+
+    next := filepath.Join(resolved, elem)
+    info, err := os.Lstat(next)
+    if err != nil || info.Mode()&os.ModeSymlink == 0 {
+      resolved = next
+      continue
+    }
+
+    links++
+    if links > maxSymlinks {
+      return "", errors.New("too many levels of symbolic links")
+    }
+    target, err := os.Readlink(next)
+    if err != nil {
+      resolved = next
+      continue
+    }
+    // relative links are resolved from the directory the link is in, which
+    // is what `resolved` still points at
+    if filepath.IsAbs(target) {
+      resolved = "/"
+    }
+    rest = append(strings.Split(target, "/"), rest...)
+  }
+
+  return resolved, nil
+}
+
+// unescapeMountpoint undoes the octal escaping the kernel applies to spaces,
+// tabs, newlines and backslashes in mountpoints, eg: `/mnt/my\040docs`.
+func unescapeMountpoint(mountpoint string) string {
+  if !strings.Contains(mountpoint, `\`) {
+    return mountpoint
+  }
+
+  var b strings.Builder
+  for i := 0; i < len(mountpoint); i++ {
+    if mountpoint[i] == '\\' && i+4 <= len(mountpoint) {
+      if c, err := strconv.ParseUint(mountpoint[i+1:i+4], 8, 8); err == nil {
+        b.WriteByte(byte(c))
+        i += 3
+        continue
+      }
+    }
+    b.WriteByte(mountpoint[i])
+  }
+
+  return b.String()
+}
@@ -0,0 +1,101 @@
+package nfsmountstats_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountFor(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  content, err  := procfs.ReadMountstats()
+  if err != nil {
+    t.Errorf("couldn't read mountstats file: %v", err)
+  }
+
+  mounts, err := nfsmountstats.NewMountstatsFromString(string(content))
+  if err != nil {
+    t.Errorf("error creating new Mountstats: %v", err)
+  }
+
+  // a file inside an nfs mount 
+  dev, err := mounts.MountFor("/mnt/nfs1/docs/reports/2024.pdf")
+  if assert.NoError(t, err) {
+    assert.Equal(t, "/mnt/nfs1/docs", dev.Mountpoint)
+    assert.Equal(t, "nfs4", dev.MountType)
+  }
+
+  // docs_work shares a prefix with docs but is a different mount 
+  dev, err = mounts.MountFor("/mnt/nfs1/docs_work/../docs_work/notes.txt")
+  if assert.NoError(t, err) {
+    assert.Equal(t, "/mnt/nfs1/docs_work", dev.Mountpoint)
+  }
+
+  // nested mounts: /run/user/1000/gvfs is inside the /run/user/1000 tmpfs 
+  dev, err = mounts.MountFor("/run/user/1000/gvfs/smb-share")
+  if assert.NoError(t, err) {
+    assert.Equal(t, "/run/user/1000/gvfs", dev.Mountpoint)
+  }
+  dev, err = mounts.MountFor("/run/user/1000/bus")
+  if assert.NoError(t, err) {
+    assert.Equal(t, "/run/user/1000", dev.Mountpoint)
+  }
+
+  // anything else falls through to the root filesystem 
+  dev, err = mounts.MountFor("/home/jesse")
+  if assert.NoError(t, err) {
+    assert.Equal(t, "/", dev.Mountpoint)
+  }
+}
+
+func TestMountForSymlink(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  content, err  := procfs.ReadMountstats()
+  if err != nil {
+    t.Errorf("couldn't read mountstats file: %v", err)
+  }
+
+  mounts, err := nfsmountstats.NewMountstatsFromString(string(content))
+  if err != nil {
+    t.Errorf("error creating new Mountstats: %v", err)
+  }
+
+  // a link to a directory on an nfs mount, the target doesn't exist on the 
+  // machine running the tests, which shouldn't matter 
+  dir := t.TempDir()
+  link := filepath.Join(dir, "mail")
+  err = os.Symlink("/mailhome6/users", link)
+  if err != nil {
+    t.Fatalf("couldn't create symlink: %v", err)
+  }
+
+  dev, err := mounts.MountFor(filepath.Join(link, "jesse", "Maildir"))
+  if assert.NoError(t, err) {
+    assert.Equal(t, "/mailhome6", dev.Mountpoint)
+  }
+
+  // symlink loops are an error rather than a hang 
+  loop := filepath.Join(dir, "loop")
+  err = os.Symlink(loop, loop)
+  if err != nil {
+    t.Fatalf("couldn't create symlink: %v", err)
+  }
+  _, err = mounts.MountFor(loop)
+  assert.Error(t, err)
+}
+
+func TestMountForEscapedMountpoint(t *testing.T) {
+  mounts, err := nfsmountstats.NewMountstatsFromString("device /dev/sda1 mounted on / with fstype ext4\ndevice 10.0.2.31:/volume1/Public mounted on /mnt/public\\040docs with fstype nfs4\n")
+  if err != nil {
+    t.Fatalf("error creating new Mountstats: %v", err)
+  }
+
+  dev, err := mounts.MountFor("/mnt/public docs/a.txt")
+  if assert.NoError(t, err) {
+    assert.Equal(t, "10.0.2.31:/volume1/Public", dev.Device)
+  }
+}
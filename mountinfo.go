@@ -0,0 +1,83 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+)
+
+// MountInfo is a single line of `/proc/self/mountinfo`. Mountstats doesn't say
+// which mounts share a superblock, but mountinfo does: every mount of the same
+// superblock has the same DeviceID.
+// Mountpoint is kept escaped the way the kernel writes it (eg: `\040` for a
+// space) so it compares equal to MountDevice.Mountpoint.
+type MountInfo struct {
+  MountID      int
+  ParentID     int
+  DeviceID     string // major:minor of the superblock, eg: `0:53`
+  Root         string // the directory of the filesystem mounted here, `/` unless it's a bind or subdirectory mount
+  Mountpoint   string
+  MountOptions string
+  FSType       string
+  Source       string
+  SuperOptions string
+}
+
+// NewMountinfo reads and parses `/proc/self/mountinfo`.
+// Returns error if the file can't be read or parsed.
+func NewMountinfo() ([]MountInfo, error) {
+  content, err := procfs.ReadMountinfo()
+  if err != nil {
+    return nil, err
+  }
+
+  return ParseMountinfo(string(content))
+}
+
+// ParseMountinfo parses the content of a mountinfo file, one MountInfo per line.
+// example: `310 29 0:53 /volume1/docs /mnt/docs rw,relatime shared:640 - nfs4 10.0.2.31:/volume1/docs rw,vers=4.2`
+// Returns error if any line is malformed.
+func ParseMountinfo(content string) ([]MountInfo, error) {
+  var mounts []MountInfo
+  for idx, line := range strings.Split(content, "\n") {
+    if strings.TrimSpace(line) == "" {
+      continue
+    }
+
+    // a variable number of optional fields comes before the `-` seperator
+    before, after, ok := strings.Cut(line, " - ")
+    fields := strings.Fields(before)
+    tail := strings.Fields(after)
+    if !ok || len(fields) < 6 || len(tail) < 2 {
+      return nil, fmt.Errorf("malformed mountinfo line %d: %q", idx+1, line)
+    }
+
+    mountID, err := strconv.Atoi(fields[0])
+    if err != nil {
+      return nil, fmt.Errorf("malformed mount id on mountinfo line %d: %v", idx+1, err)
+    }
+    parentID, err := strconv.Atoi(fields[1])
+    if err != nil {
+      return nil, fmt.Errorf("malformed parent id on mountinfo line %d: %v", idx+1, err)
+    }
+
+    mount := MountInfo{
+      MountID: mountID,
+      ParentID: parentID,
+      DeviceID: fields[2],
+      Root: fields[3],
+      Mountpoint: fields[4],
+      MountOptions: fields[5],
+      FSType: tail[0],
+      Source: tail[1],
+    }
+    if len(tail) > 2 {
+      mount.SuperOptions = tail[2]
+    }
+    mounts = append(mounts, mount)
+  }
+
+  return mounts, nil
+}
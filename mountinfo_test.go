@@ -0,0 +1,52 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMountinfo(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  mounts, err := nfsmountstats.NewMountinfo()
+  if err != nil {
+    t.Fatalf("couldn't read mountinfo: %v", err)
+  }
+  assert.Len(t, mounts, 17)
+
+  var docs *nfsmountstats.MountInfo
+  for idx := range mounts {
+    if mounts[idx].Mountpoint == "/mnt/nfs1/docs" {
+      docs = &mounts[idx]
+    }
+  }
+  if assert.NotNil(t, docs) {
+    assert.Equal(t, "0:53", docs.DeviceID)
+    assert.Equal(t, "nfs4", docs.FSType)
+    assert.NotEqual(t, "/", docs.Root)
+    assert.NotEmpty(t, docs.SuperOptions)
+  }
+}
+
+func TestParseMountinfo(t *testing.T) {
+  mounts, err := nfsmountstats.ParseMountinfo("310 29 0:53 /volume1/docs /mnt/docs rw,relatime shared:640 master:1 - nfs4 10.0.2.31:/volume1/docs rw,vers=4.2\n")
+  assert.NoError(t, err)
+  assert.Equal(t, []nfsmountstats.MountInfo{{
+    MountID: 310,
+    ParentID: 29,
+    DeviceID: "0:53",
+    Root: "/volume1/docs",
+    Mountpoint: "/mnt/docs",
+    MountOptions: "rw,relatime",
+    FSType: "nfs4",
+    Source: "10.0.2.31:/volume1/docs",
+    SuperOptions: "rw,vers=4.2",
+  }}, mounts)
+
+  _, err = nfsmountstats.ParseMountinfo("310 29 0:53 /volume1/docs /mnt/docs rw,relatime nfs4 10.0.2.31:/volume1/docs rw")
+  assert.Error(t, err)
+  _, err = nfsmountstats.ParseMountinfo("x 29 0:53 / /mnt/docs rw - nfs4 10.0.2.31:/volume1/docs rw")
+  assert.Error(t, err)
+}
@@ -3,15 +3,24 @@ package nfsmountstats
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessegalley/nfsmountstats/internal/procfs"
 )
 
+// ErrTornRead is returned (wrapped) by NewMountstats when no consistent copy of 
+// `/proc/self/mountstats` could be read within the retry budget.
+var ErrTornRead = procfs.ErrTornRead
+
 // Mountstats struct is a representation of the content in `/proc/self/mountstats`
 type Mountstats struct {
-  Devices []MountDevice
+  Devices     []MountDevice
+  ReadRetries int // how many torn reads were retried before this snapshot was taken 
+  Timestamp   time.Time     // when the snapshot was captured, carries a monotonic clock reading 
+  Uptime      time.Duration // kernel uptime when the snapshot was captured, 0 if unknown 
 }
 
 // GetNFSDevices retuns a slice of pointers to any devices which are NFS 
@@ -41,12 +50,21 @@ func (m *Mountstats) GetNFSMountMap() map[string]*MountDevice {
 // NewMountstats constructs a new Mountstats struct from content, which should be 
 // a string containing the content of `/proc/self/mountstats`, calls Parse, and 
 // returns a pointer to the new instance. 
+// The file is read with procfs.ReadMountstatsConsistent so that a snapshot is 
+// never built from a torn read, the number of retries it took is kept in 
+// ReadRetries.
+// The snapshot is stamped with the capture time and kernel uptime so that rates 
+// can be computed over the true interval between two snapshots.
 // Returns error if the underlying Parse() call fails.
 func NewMountstats() (*Mountstats, error) {
-  content, err  := procfs.ReadMountstats()
+  content, retries, err  := procfs.ReadMountstatsConsistent(procfs.DefaultReadRetries)
   if err != nil {
     return nil, err
   }
+  timestamp := time.Now()
+  // uptime is only a fallback clock for rates, not having it isn't worth 
+  // failing the whole snapshot over 
+  uptime, _ := procfs.ReadUptime()
 
   if len(content) == 0 {
     return nil, err
@@ -56,6 +74,9 @@ func NewMountstats() (*Mountstats, error) {
   if err != nil {
     return nil, err 
   }
+  mounts.ReadRetries = retries
+  mounts.Timestamp = timestamp
+  mounts.Uptime = uptime
 
   return mounts, nil
 }
@@ -113,6 +134,8 @@ type MountDevice struct {
   MountType   string  // the type of the mount 
   NFSInfo     NFSInfo // a struct of NFS info for NFS types
   OtherInfo   string  // additional info for other types 
+  Labels      map[string]string // optional metadata attached after parsing, eg: owner labels 
+  Kubernetes  *KubernetesVolume // kubelet volume info decoded from Mountpoint, nil if not a kubelet mount 
 
   rawContent  string  // raw string content of this mount 
 }
@@ -154,6 +177,10 @@ func (d *MountDevice) Parse(text string) error {
   d.Device = fields[1]
   d.Mountpoint = fields[4]
   d.MountType = fields[7]
+
+  // kubelet managed mountpoints carry the pod and volume they belong to 
+  // in their path, so decode that while we're here (nil for anything else) 
+  d.Kubernetes, _ = ParseKubeletPath(d.Mountpoint)
   
   // the mount has additional lines of information, for NFS (all we care about for now)
   // it means the nfs details, stats, counters etc, so we will attempt to parse all
@@ -186,11 +213,14 @@ func (d *MountDevice) Parse(text string) error {
 // This struct should be empty (and/or ignored) for any non-NFS mount.
 type NFSInfo struct {
   Opts        string 
+  Options     NFSMountOptions // Opts parsed into a map 
   Age         uint64 
   Events      NFSEventCounters
   Bytes       NFSByteCounters 
   Transport   NFSTransportCounters 
   RPCOpStats  map[string]RPCOpStat
+  RPCOps      []string // names of the ops in RPCOpStats, in the order the kernel lists them 
+  HasErrStats bool     // the per-op lines have the errors column, older kernels don't print it even with statvers 1.1
   Other       map[string]string
 }
 
@@ -266,10 +296,11 @@ func (i *NFSInfo) Parse(content string) error {
       i.Transport = transportCounters
     case "opts:":
       // NFS mount options 
-      // for now I think the string representation of the opts is fine 
-      // since this is how it's presented in mount or fstab anyway 
-      // TODO: create some data structure and parser for the opts... if required 
+      // the string representation of the opts is kept as is since this is 
+      // how it's presented in mount or fstab anyway, but we also parse it 
+      // into a map for anything that needs to look at individual options 
       i.Opts = line 
+      i.Options = ParseNFSMountOptions(line)
     case "per-op":
       // per-op detailed stats, if we're here it means we want to break 
       // out of the loop because we want to parse all of these seperately 
@@ -749,13 +780,34 @@ func (i *NFSInfo) ParsePerOpStats(stats []string) error {
 
     if len(intFields) >= 9 {
       opstats.ErrStats = intFields[8]
+      i.HasErrStats = true
     }
 
+    if _, ok := i.RPCOpStats[op]; !ok {
+      i.RPCOps = append(i.RPCOps, op)
+    }
     i.RPCOpStats[op] = opstats
   }
   return nil
 }
 
+// OpNames returns the names of every op in RPCOpStats in the order the kernel 
+// lists them, or sorted by name if the order isn't known (eg: the map was 
+// filled in by hand).
+func (i *NFSInfo) OpNames() []string {
+  if len(i.RPCOps) == len(i.RPCOpStats) {
+    return i.RPCOps
+  }
+
+  names := make([]string, 0, len(i.RPCOpStats))
+  for op := range i.RPCOpStats {
+    names = append(names, op)
+  }
+  sort.Strings(names)
+
+  return names
+}
+
 // RPCOpStat holds the data for each line of "per-op" stats in an NFS mount. 
 type RPCOpStat struct {
   Operations    uint64 
@@ -28,6 +28,17 @@ func TestMakeMountstats(t *testing.T) {
   // Should be 38 devices in the testdata file.
   assert.Equal(t, 38, len(mounts.Devices))
 }
+func TestNewMountstats(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  mounts, err := nfsmountstats.NewMountstats()
+  if err != nil {
+    t.Fatalf("error creating new Mountstats: %v", err)
+  }
+
+  // the testdata file never changes, so no retries should have been needed 
+  assert.Equal(t, 38, len(mounts.Devices))
+  assert.Equal(t, 0, mounts.ReadRetries)
+}
 
 func TestGetNfsDevices(t *testing.T) {
   procfs.PathPrefix = "testdata"
@@ -423,6 +434,7 @@ func TestParsePerOpStatsNFSv4(t *testing.T) {
   assert.Equal(t, uint64(134), nfsinfo.RPCOpStats["CREATE_SESSION"].CumTotalReqTime)
   // ErrStats seems to only be in newer versions 
   assert.Equal(t, uint64(35), nfsinfo.RPCOpStats["CREATE_SESSION"].ErrStats)
+  assert.True(t, nfsinfo.HasErrStats)
 
   // `READDIRPLUS` and `FSSTAT` are two ops that NFSv4 shouldn't have in it's stats 
   // so we will make sure that we _did not_ parse them for some reason 
@@ -485,6 +497,7 @@ func TestParsePerOpStatsNFSv3(t *testing.T) {
   assert.Equal(t, uint64(7704966112), nfsinfo.RPCOpStats["WRITE"].BytesSent)
   // ErrStats should always be 0 because this is nfsv3 (statvers 1) 
   assert.Equal(t, uint64(0), nfsinfo.RPCOpStats["WRITE"].ErrStats)
+  assert.False(t, nfsinfo.HasErrStats)
 
   // `READDIRPLUS` and `FSSTAT` are two ops excludive to NFSv3 
   // READDIRPLUS: 1362042 1362042 0 201582216 4278783472 5037 34153934 34201891
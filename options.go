@@ -0,0 +1,58 @@
+package nfsmountstats
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NFSMountOptions is the parsed form of the `opts:` line of an NFS mount, 
+// mapping each option name to its value. Flag options that don't have a 
+// value, like `hard` or `rw`, map to an empty string.
+type NFSMountOptions map[string]string
+
+// ParseNFSMountOptions parses a comma seperated mount option string, with or 
+// without the leading `opts:` label. 
+// example: `opts:	rw,vers=3,rsize=32768,hard,proto=tcp,sec=sys,mountaddr=10.0.47.9`
+func ParseNFSMountOptions(opts string) NFSMountOptions {
+  options := make(NFSMountOptions)
+
+  opts = strings.TrimSpace(opts)
+  opts = strings.TrimSpace(strings.TrimPrefix(opts, "opts:"))
+  if opts == "" {
+    return options
+  }
+
+  for _, opt := range strings.Split(opts, ",") {
+    name, value, _ := strings.Cut(opt, "=")
+    options[name] = value
+  }
+
+  return options
+}
+
+// Has reports whether the option `name` is present, with or without a value.
+func (o NFSMountOptions) Has(name string) bool {
+  _, ok := o[name]
+  return ok
+}
+
+// Get returns the value of option `name`, or an empty string if it's not set.
+func (o NFSMountOptions) Get(name string) string {
+  return o[name]
+}
+
+// Uint returns the value of option `name` as an integer, eg: rsize, timeo. 
+// The bool is false if the option isn't set or isn't a number.
+func (o NFSMountOptions) Uint(name string) (uint64, bool) {
+  value, ok := o[name]
+  if !ok {
+    return 0, false
+  }
+
+  parsed, err := strconv.ParseUint(value, 10, 64)
+  if err != nil {
+    return 0, false
+  }
+
+  return parsed, true
+}
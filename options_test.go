@@ -0,0 +1,25 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNFSMountOptions(t *testing.T) {
+  opts := nfsmountstats.ParseNFSMountOptions(`opts:	rw,vers=3,rsize=32768,wsize=16384,hard,nolock,proto=tcp,sec=sys,mountaddr=10.0.47.9`)
+
+  assert.True(t, opts.Has("hard"))
+  assert.True(t, opts.Has("rw"))
+  assert.False(t, opts.Has("soft"))
+  assert.Equal(t, "3", opts.Get("vers"))
+  assert.Equal(t, "tcp", opts.Get("proto"))
+  rsize, ok := opts.Uint("rsize")
+  assert.True(t, ok)
+  assert.Equal(t, uint64(32768), rsize)
+  _, ok = opts.Uint("proto")
+  assert.False(t, ok)
+
+  assert.Equal(t, 0, len(nfsmountstats.ParseNFSMountOptions("")))
+}
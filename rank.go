@@ -0,0 +1,81 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RankField is what RankOps orders ops on.
+type RankField string
+
+const (
+  RankByTime   RankField = "time"   // total time from queueing to reply, CumTotalReqTime
+  RankByQueue  RankField = "queue"  // time waiting to be sent, CumQueueTime
+  RankByCount  RankField = "count"  // number of ops
+  RankByBytes  RankField = "bytes"  // bytes sent and received
+  RankByErrors RankField = "errors" // ops that failed, statvers 1.1+
+)
+
+// OpRank is a single op's place in a ranking.
+type OpRank struct {
+  Op    string
+  Field RankField
+  Value uint64  // the value of Field, milliseconds for times
+  Share float64 // Value as a ratio of the total of every op, between 0 and 1
+  Stat  RPCOpStat
+}
+
+// String describes the rank, eg: `GETATTR is 62% of time`.
+func (r OpRank) String() string {
+  return fmt.Sprintf("%s is %.0f%% of %s", r.Op, r.Share*100, r.Field)
+}
+
+// RankOps orders the ops in RPCOpStats on `field`, largest first, and returns
+// the top `n` of them, or all of them if `n` is 0 or less. Ops with nothing to
+// rank on are left out, and ops with the same value are in the order the kernel
+// lists them.
+// Works the same since mount and over a MountDeviceDelta, as its NFSInfo holds
+// the difference of the counters.
+func (i *NFSInfo) RankOps(field RankField, n int) []OpRank {
+  var total uint64
+  var ranks []OpRank
+  for _, op := range i.OpNames() {
+    s := i.RPCOpStats[op]
+    value := field.value(s)
+    total += value
+    if value == 0 {
+      continue
+    }
+    ranks = append(ranks, OpRank{Op: op, Field: field, Value: value, Stat: s})
+  }
+
+  sort.SliceStable(ranks, func(a, b int) bool {
+    return ranks[a].Value > ranks[b].Value
+  })
+  if n > 0 && len(ranks) > n {
+    ranks = ranks[:n]
+  }
+  for idx := range ranks {
+    ranks[idx].Share = ratio(ranks[idx].Value, total)
+  }
+
+  return ranks
+}
+
+// value returns what `s` is ranked on.
+func (f RankField) value(s RPCOpStat) uint64 {
+  switch f {
+  case RankByTime:
+    return s.CumTotalReqTime
+  case RankByQueue:
+    return s.CumQueueTime
+  case RankByCount:
+    return s.Operations
+  case RankByBytes:
+    return s.BytesSent + s.BytesReceived
+  case RankByErrors:
+    return s.ErrStats
+  }
+
+  return 0
+}
@@ -0,0 +1,65 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankOpsByTime(t *testing.T) {
+  mounts := loadTestMountstats(t)
+  info := &findDevice(t, mounts, "/mailhome6").NFSInfo
+
+  ranks := info.RankOps(nfsmountstats.RankByTime, 3)
+  assert.Len(t, ranks, 3)
+  assert.Equal(t, "READDIRPLUS", ranks[0].Op)
+  assert.Equal(t, uint64(34201891), ranks[0].Value)
+  assert.InDelta(t, 0.359100, ranks[0].Share, 0.000001)
+  assert.Equal(t, "READDIRPLUS is 36% of time", ranks[0].String())
+  assert.Equal(t, "READ", ranks[1].Op)
+  assert.Equal(t, "WRITE", ranks[2].Op)
+
+  // WRITE spends the most time queued
+  ranks = info.RankOps(nfsmountstats.RankByQueue, 1)
+  assert.Equal(t, "WRITE", ranks[0].Op)
+  assert.InDelta(t, 0.948608, ranks[0].Share, 0.000001)
+
+  // ops that never ran aren't ranked
+  assert.Len(t, info.RankOps(nfsmountstats.RankByCount, 0), 18)
+}
+
+func TestRankOpsByErrors(t *testing.T) {
+  mounts := loadTestMountstats(t)
+  info := &findDevice(t, mounts, "/mnt/nfs1/docs").NFSInfo
+
+  ranks := info.RankOps(nfsmountstats.RankByErrors, 4)
+  var ops []string
+  for _, rank := range ranks {
+    ops = append(ops, rank.Op)
+  }
+  // ties stay in the order the kernel lists them
+  assert.Equal(t, []string{"LOOKUP", "OPEN", "CREATE_SESSION", "DESTROY_SESSION"}, ops)
+  assert.InDelta(t, 1728.0/2253.0, ranks[0].Share, 0.000001)
+  assert.Equal(t, uint64(1728), ranks[0].Stat.ErrStats)
+}
+
+func TestRankOpsDelta(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    for op, bytes := range map[string]uint64{"GETATTR": 300, "WRITE": 700} {
+      s := dev.NFSInfo.RPCOpStats[op]
+      s.Operations += 1
+      s.BytesSent += bytes
+      dev.NFSInfo.RPCOpStats[op] = s
+    }
+  })
+
+  ranks := delta.GetMountMap()["/mailhome6"].NFSInfo.RankOps(nfsmountstats.RankByBytes, 0)
+  assert.Len(t, ranks, 2)
+  assert.Equal(t, "WRITE", ranks[0].Op)
+  assert.InDelta(t, 0.7, ranks[0].Share, 0.000001)
+  assert.InDelta(t, 0.3, ranks[1].Share, 0.000001)
+}
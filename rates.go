@@ -0,0 +1,281 @@
+package nfsmountstats
+
+import (
+	"errors"
+	"time"
+)
+
+// Rates holds every counter of every NFS mount as a per second rate over the
+// interval between two snapshots.
+type Rates struct {
+  Devices  []MountDeviceRates
+  Interval time.Duration // time between the two snapshots
+}
+
+// MountDeviceRates holds the per second rates of a single NFS mount.
+type MountDeviceRates struct {
+  Device     string
+  Mountpoint string
+  MountType  string
+  Interval   time.Duration // the interval the rates were computed over
+  Reset      bool          // some counters were reset, see MountDeviceDelta
+  Events     NFSEventRates
+  Bytes      NFSByteRates
+  Transport  NFSTransportRates
+  RPCOpStats map[string]RPCOpRates
+}
+
+// NFSEventRates is NFSEventCounters per second.
+type NFSEventRates struct {
+  InodeRevalidates  float64
+  DentryRevalidates float64
+  DataInvalidates   float64
+  AttrInvalidates   float64
+  VfsOpen           float64
+  VfsLookup         float64
+  VfsPermission     float64
+  VfsUpdatePage     float64
+  VfsReadPage       float64
+  VfsReadPages      float64
+  VfsWritePage      float64
+  VfsWritePages     float64
+  VfsReaddir        float64
+  VfsSetAttr        float64
+  VfsFlush          float64
+  VfsFsync          float64
+  VfsLock           float64
+  VfsRelease        float64
+  CongestionWait    float64
+  SetAttrTrunc      float64
+  ExtendWrite       float64
+  SillyRenames      float64
+  ShortReads        float64
+  ShortWrites       float64
+  Delay             float64
+  PNFSRead          float64
+  PNFSWrite         float64
+}
+
+// NFSByteRates is NFSByteCounters per second.
+type NFSByteRates struct {
+  NormalReadBytes  float64
+  NormalWriteBytes float64
+  DirectReadBytes  float64
+  DirectWriteBytes float64
+  ServerReadBytes  float64
+  ServerWriteBytes float64
+  ReadPages        float64
+  WritePages       float64
+}
+
+// NFSTransportRates holds the counters of any of the transport protocols per
+// second. Counters the protocol doesn't have are always 0, eg: the RDMA chunk
+// counters on TCP. Fields that aren't counters (port, connect time, idle time,
+// max slots) don't have a rate and are left out.
+type NFSTransportRates struct {
+  Protocol        string
+  BindCount       float64
+  ConnectCount    float64
+  RpcSends        float64
+  RpcReceives     float64
+  BadXids         float64
+  InflightSends   float64
+  BacklogUtil     float64
+  CumSendingQueue float64
+  CumPendingQueue float64
+  ReadChunks      float64
+  WriteChunks     float64
+  ReplyChunks     float64
+  TotalRdmaReq    float64
+  TotalRdmaRep    float64
+  Pullup          float64
+  Fixup           float64
+  Hardway         float64
+  FailedMarshal   float64
+  BadReply        float64
+}
+
+// RPCOpRates is RPCOpStat per second. The cumulative times are in milliseconds
+// per second.
+type RPCOpRates struct {
+  Operations      float64
+  Transmissions   float64
+  MajorTimeouts   float64
+  BytesSent       float64
+  BytesReceived   float64
+  CumQueueTime    float64
+  CumRespTime     float64
+  CumTotalReqTime float64
+  ErrStats        float64
+}
+
+// Rates computes the per second rate of every counter between the snapshots
+// `prev` and `m`, over the true time elapsed between them (see Delta for how
+// the interval is measured and how resets are handled).
+// Returns an error if either snapshot wasn't timestamped, as the result would
+// silently fall back to the one second resolution of the mount age.
+func (m *Mountstats) Rates(prev *Mountstats) (*Rates, error) {
+  if prev == nil {
+    return nil, errors.New("no previous snapshot to compute rates from")
+  }
+  if (m.Timestamp.IsZero() || prev.Timestamp.IsZero()) && (m.Uptime == 0 || prev.Uptime == 0) {
+    return nil, errors.New("snapshots have no timestamp, can't compute the interval between them")
+  }
+
+  delta := m.Delta(prev)
+  if delta.Interval <= 0 {
+    return nil, errors.New("snapshots are not in order, interval is not positive")
+  }
+
+  return delta.Rates(), nil
+}
+
+// Rates converts every device delta into per second rates.
+func (d *MountstatsDelta) Rates() *Rates {
+  rates := Rates{Interval: d.Interval}
+  for idx := range d.Devices {
+    rates.Devices = append(rates.Devices, d.Devices[idx].Rates())
+  }
+
+  return &rates
+}
+
+// GetMountMap returns a map of the device rates keyed on mountpoint.
+func (r *Rates) GetMountMap() map[string]*MountDeviceRates {
+  ratemap := make(map[string]*MountDeviceRates)
+  for idx := range r.Devices {
+    ratemap[r.Devices[idx].Mountpoint] = &r.Devices[idx]
+  }
+
+  return ratemap
+}
+
+// Rates converts the counters of a single device delta into per second rates
+// over its Interval. A zero Interval gives all zero rates.
+func (d *MountDeviceDelta) Rates() MountDeviceRates {
+  seconds := d.Interval.Seconds()
+  ps := func(v uint64) float64 {
+    return perSecond(v, seconds)
+  }
+
+  e := d.NFSInfo.Events
+  b := d.NFSInfo.Bytes
+  rates := MountDeviceRates{
+    Device: d.Device,
+    Mountpoint: d.Mountpoint,
+    MountType: d.MountType,
+    Interval: d.Interval,
+    Reset: d.Reset,
+    Events: NFSEventRates{
+      InodeRevalidates: ps(e.InodeRevalidates),
+      DentryRevalidates: ps(e.DentryRevalidates),
+      DataInvalidates: ps(e.DataInvalidates),
+      AttrInvalidates: ps(e.AttrInvalidates),
+      VfsOpen: ps(e.VfsOpen),
+      VfsLookup: ps(e.VfsLookup),
+      VfsPermission: ps(e.VfsPermission),
+      VfsUpdatePage: ps(e.VfsUpdatePage),
+      VfsReadPage: ps(e.VfsReadPage),
+      VfsReadPages: ps(e.VfsReadPages),
+      VfsWritePage: ps(e.VfsWritePage),
+      VfsWritePages: ps(e.VfsWritePages),
+      VfsReaddir: ps(e.VfsReaddir),
+      VfsSetAttr: ps(e.VfsSetAttr),
+      VfsFlush: ps(e.VfsFlush),
+      VfsFsync: ps(e.VfsFsync),
+      VfsLock: ps(e.VfsLock),
+      VfsRelease: ps(e.VfsRelease),
+      CongestionWait: ps(e.CongestionWait),
+      SetAttrTrunc: ps(e.SetAttrTrunc),
+      ExtendWrite: ps(e.ExtendWrite),
+      SillyRenames: ps(e.SillyRenames),
+      ShortReads: ps(e.ShortReads),
+      ShortWrites: ps(e.ShortWrites),
+      Delay: ps(e.Delay),
+      PNFSRead: ps(e.PNFSRead),
+      PNFSWrite: ps(e.PNFSWrite),
+    },
+    Bytes: NFSByteRates{
+      NormalReadBytes: ps(b.NormalReadBytes),
+      NormalWriteBytes: ps(b.NormalWriteBytes),
+      DirectReadBytes: ps(b.DirectReadBytes),
+      DirectWriteBytes: ps(b.DirectWriteBytes),
+      ServerReadBytes: ps(b.ServerReadBytes),
+      ServerWriteBytes: ps(b.ServerWriteBytes),
+      ReadPages: ps(b.ReadPages),
+      WritePages: ps(b.WritePages),
+    },
+    RPCOpStats: make(map[string]RPCOpRates, len(d.NFSInfo.RPCOpStats)),
+  }
+
+  switch t := d.NFSInfo.Transport.(type) {
+  case *NFSTransportCountersUDP:
+    rates.Transport = NFSTransportRates{
+      Protocol: t.Protocol(),
+      BindCount: ps(t.BindCount),
+      RpcSends: ps(t.RpcSends),
+      RpcReceives: ps(t.RpcReceives),
+      BadXids: ps(t.BadXids),
+      InflightSends: ps(t.InflightSends),
+      BacklogUtil: ps(t.BacklogUtil),
+    }
+  case *NFSTransportCountersTCP:
+    rates.Transport = NFSTransportRates{
+      Protocol: t.Protocol(),
+      BindCount: ps(t.BindCount),
+      ConnectCount: ps(t.ConnectCount),
+      RpcSends: ps(t.RpcSends),
+      RpcReceives: ps(t.RpcReceives),
+      BadXids: ps(t.BadXids),
+      InflightSends: ps(t.InflightSends),
+      BacklogUtil: ps(t.BacklogUtil),
+      CumSendingQueue: ps(t.CumSendingQueue),
+      CumPendingQueue: ps(t.CumPendingQueue),
+    }
+  case *NFSTransportCountersRDMA:
+    rates.Transport = NFSTransportRates{
+      Protocol: t.Protocol(),
+      BindCount: ps(t.BindCount),
+      ConnectCount: ps(t.ConnectCount),
+      RpcSends: ps(t.RpcSends),
+      RpcReceives: ps(t.RpcReceives),
+      BadXids: ps(t.BadXids),
+      BacklogUtil: ps(t.BacklogUtil),
+      ReadChunks: ps(t.ReadChunks),
+      WriteChunks: ps(t.WriteChunks),
+      ReplyChunks: ps(t.ReplyChunks),
+      TotalRdmaReq: ps(t.TotalRdmaReq),
+      TotalRdmaRep: ps(t.TotalRdmaRep),
+      Pullup: ps(t.Pullup),
+      Fixup: ps(t.Fixup),
+      Hardway: ps(t.Hardway),
+      FailedMarshal: ps(t.FailedMarshal),
+      BadReply: ps(t.BadReply),
+    }
+  }
+
+  for op, s := range d.NFSInfo.RPCOpStats {
+    rates.RPCOpStats[op] = RPCOpRates{
+      Operations: ps(s.Operations),
+      Transmissions: ps(s.Transmissions),
+      MajorTimeouts: ps(s.MajorTimeouts),
+      BytesSent: ps(s.BytesSent),
+      BytesReceived: ps(s.BytesReceived),
+      CumQueueTime: ps(s.CumQueueTime),
+      CumRespTime: ps(s.CumRespTime),
+      CumTotalReqTime: ps(s.CumTotalReqTime),
+      ErrStats: ps(s.ErrStats),
+    }
+  }
+
+  return rates
+}
+
+// perSecond divides a counter by an interval in seconds, 0 for an empty interval.
+func perSecond(v uint64, seconds float64) float64 {
+  if seconds <= 0 {
+    return 0
+  }
+
+  return float64(v) / seconds
+}
@@ -0,0 +1,91 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/jessegalley/nfsmountstats/internal/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMountstatsTimestamp(t *testing.T) {
+  procfs.PathPrefix = "testdata"
+  before := time.Now()
+  mounts, err := nfsmountstats.NewMountstats()
+  if err != nil {
+    t.Fatalf("error creating new Mountstats: %v", err)
+  }
+
+  assert.False(t, mounts.Timestamp.Before(before))
+  assert.Equal(t, 2919220470*time.Millisecond, mounts.Uptime)
+}
+
+func TestRates(t *testing.T) {
+  // the sampler was late, the snapshots are 12.5s apart even though the
+  // kernel age only moved by 12s
+  prev, cur := loadTestSnapshots(t, 12500*time.Millisecond, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 12
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations += 250
+    read.BytesReceived += 25 * 1048576
+    dev.NFSInfo.RPCOpStats["READ"] = read
+    dev.NFSInfo.Events.VfsOpen += 50
+    dev.NFSInfo.Bytes.NormalReadBytes += 5000
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 1000
+  })
+
+  rates, err := cur.Rates(prev)
+  if err != nil {
+    t.Fatalf("couldn't compute rates: %v", err)
+  }
+
+  assert.Equal(t, 12500*time.Millisecond, rates.Interval)
+  mailhome := rates.GetMountMap()["/mailhome6"]
+  assert.Equal(t, 12500*time.Millisecond, mailhome.Interval)
+  assert.InDelta(t, 20.0, mailhome.RPCOpStats["READ"].Operations, 0.0001)
+  assert.InDelta(t, 2097152.0, mailhome.RPCOpStats["READ"].BytesReceived, 0.0001)
+  assert.InDelta(t, 4.0, mailhome.Events.VfsOpen, 0.0001)
+  assert.InDelta(t, 400.0, mailhome.Bytes.NormalReadBytes, 0.0001)
+  assert.InDelta(t, 80.0, mailhome.Transport.RpcSends, 0.0001)
+  assert.Equal(t, "tcp", mailhome.Transport.Protocol)
+  assert.Equal(t, 0.0, mailhome.RPCOpStats["WRITE"].Operations)
+}
+
+func TestRatesUptimeFallback(t *testing.T) {
+  prev := loadTestMountstats(t)
+  cur := loadTestMountstats(t)
+
+  // snapshots taken by another process only have the kernel uptime
+  prev.Uptime = 100 * time.Second
+  cur.Uptime = 104 * time.Second
+  dev := findDevice(t, cur, "/webmail0")
+  getattr := dev.NFSInfo.RPCOpStats["GETATTR"]
+  getattr.Operations += 100
+  dev.NFSInfo.RPCOpStats["GETATTR"] = getattr
+
+  rates, err := cur.Rates(prev)
+  if err != nil {
+    t.Fatalf("couldn't compute rates: %v", err)
+  }
+  assert.InDelta(t, 25.0, rates.GetMountMap()["/webmail0"].RPCOpStats["GETATTR"].Operations, 0.0001)
+}
+
+func TestRatesErrors(t *testing.T) {
+  prev := loadTestMountstats(t)
+  cur := loadTestMountstats(t)
+
+  // no timestamps at all
+  _, err := cur.Rates(prev)
+  assert.Error(t, err)
+  _, err = cur.Rates(nil)
+  assert.Error(t, err)
+
+  // out of order snapshots
+  cur.Timestamp = time.Now()
+  prev.Timestamp = cur.Timestamp.Add(time.Second)
+  _, err = cur.Rates(prev)
+  assert.Error(t, err)
+}
@@ -0,0 +1,381 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The reports in this file reproduce the output of the mountstats(8) script
+// from nfs-utils, in its --nfs, --rpc and --xprt modes. Each report is built as
+// structured data from either a MountDevice (the "since mount" form) or a
+// MountDeviceDelta (the interval form, like `mountstats --since`), and has a
+// Text method that renders it the way mountstats does.
+
+// NFSReport is the data behind `mountstats --nfs`.
+type NFSReport struct {
+  Device          string
+  Mountpoint      string
+  Options         string        // the mount options, as they appear in the opts: line
+  Age             time.Duration // the mount age, or the length of the interval
+  Capabilities    []string      // server capabilities, from the caps: line
+  NFSv4Flags      []string      // NFSv4 capability flags, from the nfsv4: line
+  SecFlavor       int64         // the security flavor, from the sec: line
+  PseudoFlavor    int64
+  HasPseudoFlavor bool
+  Events          NFSEventCounters
+  Bytes           NFSByteCounters
+}
+
+// RPCReport is the data behind `mountstats --rpc`.
+type RPCReport struct {
+  Device     string
+  Mountpoint string
+  Sends      uint64
+  Receives   uint64
+  BadXids    uint64
+  AvgBacklog float64       // average backlog queue length per request
+  Ops        []RPCOpReport // every op that ran, in kernel order
+}
+
+// RPCOpReport is a single op in an RPCReport. The averages are per op, and the
+// times are in milliseconds.
+type RPCOpReport struct {
+  Op               string
+  Operations       uint64
+  OpsPercent       float64 // share of all rpc requests sent
+  Retrans          uint64
+  RetransPercent   float64
+  MajorTimeouts    uint64
+  Errors           uint64
+  ErrorsPercent    float64
+  AvgBytesSent     float64
+  AvgBytesReceived float64
+  AvgBacklogWait   float64
+  AvgRTT           float64
+  AvgExe           float64
+}
+
+// XprtReport is the data behind `mountstats --xprt`.
+type XprtReport struct {
+  Device     string
+  Mountpoint string
+  Transport  NFSTransportCounters // nil if the mount has no xprt: line
+  Health     TransportHealth      // the queue averages the script prints
+}
+
+// NFSReport builds the --nfs report of the device since it was mounted.
+func (d *MountDevice) NFSReport() NFSReport {
+  return newNFSReport(d.Device, d.Mountpoint, &d.NFSInfo)
+}
+
+// NFSReport builds the --nfs report of the device over the delta interval.
+func (d *MountDeviceDelta) NFSReport() NFSReport {
+  return newNFSReport(d.Device, d.Mountpoint, &d.NFSInfo)
+}
+
+// RPCReport builds the --rpc report of the device since it was mounted.
+func (d *MountDevice) RPCReport() RPCReport {
+  return newRPCReport(d.Device, d.Mountpoint, &d.NFSInfo)
+}
+
+// RPCReport builds the --rpc report of the device over the delta interval.
+func (d *MountDeviceDelta) RPCReport() RPCReport {
+  return newRPCReport(d.Device, d.Mountpoint, &d.NFSInfo)
+}
+
+// XprtReport builds the --xprt report of the device since it was mounted.
+func (d *MountDevice) XprtReport() XprtReport {
+  return XprtReport{
+    Device: d.Device,
+    Mountpoint: d.Mountpoint,
+    Transport: d.NFSInfo.Transport,
+    Health: d.NFSInfo.TransportHealth(),
+  }
+}
+
+// XprtReport builds the --xprt report of the device over the delta interval.
+func (d *MountDeviceDelta) XprtReport() XprtReport {
+  return XprtReport{
+    Device: d.Device,
+    Mountpoint: d.Mountpoint,
+    Transport: d.NFSInfo.Transport,
+    Health: d.TransportHealth(),
+  }
+}
+
+// newNFSReport builds an NFSReport from the counters in `info`.
+func newNFSReport(device string, mountpoint string, info *NFSInfo) NFSReport {
+  report := NFSReport{
+    Device: device,
+    Mountpoint: mountpoint,
+    Options: strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(info.Opts), "opts:")),
+    Age: time.Duration(info.Age) * time.Second,
+    Capabilities: splitOtherLine(info.Other["caps:"]),
+    NFSv4Flags: splitOtherLine(info.Other["nfsv4:"]),
+    Events: info.Events,
+    Bytes: info.Bytes,
+  }
+
+  for _, field := range splitOtherLine(info.Other["sec:"]) {
+    name, value, _ := strings.Cut(field, "=")
+    parsed, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+      continue
+    }
+    switch name {
+    case "flavor":
+      report.SecFlavor = parsed
+    case "pseudoflavor":
+      report.PseudoFlavor = parsed
+      report.HasPseudoFlavor = true
+    }
+  }
+
+  return report
+}
+
+// splitOtherLine splits one of the lines kept in NFSInfo.Other, eg:
+// `caps:	caps=0x3fc7,wtmult=512`, into its comma seperated fields.
+func splitOtherLine(line string) []string {
+  _, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+  value = strings.TrimSpace(value)
+  if !ok || value == "" {
+    return nil
+  }
+
+  return strings.Split(value, ",")
+}
+
+// newRPCReport builds an RPCReport from the counters in `info`.
+func newRPCReport(device string, mountpoint string, info *NFSInfo) RPCReport {
+  report := RPCReport{Device: device, Mountpoint: mountpoint}
+
+  switch t := info.Transport.(type) {
+  case *NFSTransportCountersUDP:
+    report.Sends, report.Receives, report.BadXids = t.RpcSends, t.RpcReceives, t.BadXids
+  case *NFSTransportCountersTCP:
+    report.Sends, report.Receives, report.BadXids = t.RpcSends, t.RpcReceives, t.BadXids
+  case *NFSTransportCountersRDMA:
+    report.Sends, report.Receives, report.BadXids = t.RpcSends, t.RpcReceives, t.BadXids
+  }
+  _, backlog := transportSendsAndBacklog(info.Transport)
+  if report.Sends > 0 {
+    report.AvgBacklog = float64(backlog) / float64(report.Sends)
+  }
+
+  for _, op := range info.OpNames() {
+    s := info.RPCOpStats[op]
+    // mountstats leaves out every op that never ran
+    if s.Operations == 0 {
+      continue
+    }
+
+    count := float64(s.Operations)
+    opReport := RPCOpReport{
+      Op: op,
+      Operations: s.Operations,
+      MajorTimeouts: s.MajorTimeouts,
+      Errors: s.ErrStats,
+      ErrorsPercent: float64(s.ErrStats) * 100 / count,
+      AvgBytesSent: float64(s.BytesSent) / count,
+      AvgBytesReceived: float64(s.BytesReceived) / count,
+      AvgBacklogWait: float64(s.CumQueueTime) / count,
+      AvgRTT: float64(s.CumRespTime) / count,
+      AvgExe: float64(s.CumTotalReqTime) / count,
+    }
+    if report.Sends > 0 {
+      opReport.OpsPercent = count * 100 / float64(report.Sends)
+    }
+    if s.Transmissions > s.Operations {
+      opReport.Retrans = s.Transmissions - s.Operations
+      opReport.RetransPercent = float64(opReport.Retrans) * 100 / count
+    }
+    report.Ops = append(report.Ops, opReport)
+  }
+
+  return report
+}
+
+// Text renders the report the way `mountstats --nfs` prints it.
+func (r NFSReport) Text() string {
+  var b strings.Builder
+  e := r.Events
+
+  fmt.Fprintf(&b, "Stats for %s mounted on %s:\n", r.Device, r.Mountpoint)
+  fmt.Fprintf(&b, "  NFS mount options: %s\n", r.Options)
+  fmt.Fprintf(&b, "  NFS mount age: %s\n", formatTimedelta(r.Age))
+  fmt.Fprintf(&b, "  NFS server capabilities: %s\n", strings.Join(r.Capabilities, ","))
+  if len(r.NFSv4Flags) > 0 {
+    fmt.Fprintf(&b, "  NFSv4 capability flags: %s\n", strings.Join(r.NFSv4Flags, ","))
+  }
+  if r.HasPseudoFlavor {
+    fmt.Fprintf(&b, "  NFS security flavor: %d  pseudoflavor: %d\n", r.SecFlavor, r.PseudoFlavor)
+  } else {
+    fmt.Fprintf(&b, "  NFS security flavor: %d\n", r.SecFlavor)
+  }
+
+  fmt.Fprintf(&b, "\nCache events:\n")
+  fmt.Fprintf(&b, "  data cache invalidated %d times\n", e.DataInvalidates)
+  fmt.Fprintf(&b, "  attribute cache invalidated %d times\n", e.AttrInvalidates)
+
+  fmt.Fprintf(&b, "\nVFS calls:\n")
+  fmt.Fprintf(&b, "  VFS requested %d inode revalidations\n", e.InodeRevalidates)
+  fmt.Fprintf(&b, "  VFS requested %d dentry revalidations\n", e.DentryRevalidates)
+  fmt.Fprintf(&b, "\n")
+  fmt.Fprintf(&b, "  VFS called nfs_readdir() %d times\n", e.VfsReaddir)
+  fmt.Fprintf(&b, "  VFS called nfs_lookup() %d times\n", e.VfsLookup)
+  fmt.Fprintf(&b, "  VFS called nfs_permission() %d times\n", e.VfsPermission)
+  fmt.Fprintf(&b, "  VFS called nfs_file_open() %d times\n", e.VfsOpen)
+  fmt.Fprintf(&b, "  VFS called nfs_file_flush() %d times\n", e.VfsFlush)
+  fmt.Fprintf(&b, "  VFS called nfs_lock() %d times\n", e.VfsLock)
+  fmt.Fprintf(&b, "  VFS called nfs_fsync() %d times\n", e.VfsFsync)
+  fmt.Fprintf(&b, "  VFS called nfs_file_release() %d times\n", e.VfsRelease)
+
+  fmt.Fprintf(&b, "\nVM calls:\n")
+  fmt.Fprintf(&b, "  VFS called nfs_readpage() %d times\n", e.VfsReadPage)
+  fmt.Fprintf(&b, "  VFS called nfs_readpages() %d times\n", e.VfsReadPages)
+  fmt.Fprintf(&b, "  VFS called nfs_writepage() %d times\n", e.VfsWritePage)
+  fmt.Fprintf(&b, "  VFS called nfs_writepages() %d times\n", e.VfsWritePages)
+
+  fmt.Fprintf(&b, "\nGeneric NFS counters:\n")
+  fmt.Fprintf(&b, "  File size changing operations:\n")
+  fmt.Fprintf(&b, "    truncating SETATTRs: %d  extending WRITEs: %d\n", e.SetAttrTrunc, e.ExtendWrite)
+  fmt.Fprintf(&b, "  %d silly renames\n", e.SillyRenames)
+  fmt.Fprintf(&b, "  short reads: %d  short writes: %d\n", e.ShortReads, e.ShortWrites)
+  fmt.Fprintf(&b, "  NFSERR_DELAYs from server: %d\n", e.Delay)
+  fmt.Fprintf(&b, "  pNFS READs: %d\n", e.PNFSRead)
+  fmt.Fprintf(&b, "  pNFS WRITEs: %d\n", e.PNFSWrite)
+
+  fmt.Fprintf(&b, "\nNFS byte counts:\n")
+  fmt.Fprintf(&b, "  applications read %d bytes via read(2)\n", r.Bytes.NormalReadBytes)
+  fmt.Fprintf(&b, "  applications wrote %d bytes via write(2)\n", r.Bytes.NormalWriteBytes)
+  fmt.Fprintf(&b, "  applications read %d bytes via O_DIRECT read(2)\n", r.Bytes.DirectReadBytes)
+  fmt.Fprintf(&b, "  applications wrote %d bytes via O_DIRECT write(2)\n", r.Bytes.DirectWriteBytes)
+  fmt.Fprintf(&b, "  client read %d bytes via NFS READ\n", r.Bytes.ServerReadBytes)
+  fmt.Fprintf(&b, "  client wrote %d bytes via NFS WRITE\n", r.Bytes.ServerWriteBytes)
+
+  return b.String()
+}
+
+// Text renders the report the way `mountstats --rpc` prints it. Like the
+// script, percentages and the backlog length are truncated to whole numbers.
+func (r RPCReport) Text() string {
+  var b strings.Builder
+
+  fmt.Fprintf(&b, "Stats for %s mounted on %s:\n", r.Device, r.Mountpoint)
+  fmt.Fprintf(&b, "RPC statistics:\n")
+  fmt.Fprintf(&b, "  %d RPC requests sent, %d RPC replies received (%d XIDs not found)\n", r.Sends, r.Receives, r.BadXids)
+  if r.Sends > 0 {
+    fmt.Fprintf(&b, "  average backlog queue length: %d\n", int64(r.AvgBacklog))
+  }
+  fmt.Fprintf(&b, "\n")
+
+  for _, op := range r.Ops {
+    fmt.Fprintf(&b, "%s:\n", op.Op)
+    // the script prints these with end=' ', which leaves a trailing space
+    // after the ops and retrans fields but not after the major timeouts
+    fmt.Fprintf(&b, "\t%d ops (%d%%) ", op.Operations, int64(op.OpsPercent))
+    if op.Retrans > 0 {
+      fmt.Fprintf(&b, "\t%d retrans (%d%%) ", op.Retrans, int64(op.RetransPercent))
+      fmt.Fprintf(&b, "\t%d major timeouts", op.MajorTimeouts)
+    }
+    if op.Errors > 0 {
+      fmt.Fprintf(&b, "\t%d errors (%d%%)", op.Errors, int64(op.ErrorsPercent))
+    }
+    fmt.Fprintf(&b, "\n")
+    fmt.Fprintf(&b, "\tavg bytes sent per op: %d\tavg bytes received per op: %d\n", int64(op.AvgBytesSent), int64(op.AvgBytesReceived))
+    fmt.Fprintf(&b, "\tbacklog wait: %f \tRTT: %f \ttotal execute time: %f (milliseconds)\n", op.AvgBacklogWait, op.AvgRTT, op.AvgExe)
+  }
+
+  return b.String()
+}
+
+// Text renders the report the way `mountstats --xprt` prints it. Like the
+// script, the queue lengths are averaged over the sends and truncated to whole
+// numbers, and left out when nothing was sent. The send and pending queue
+// lengths and the slots are only there on TCP with statvers 1.1+.
+func (r XprtReport) Text() string {
+  var b strings.Builder
+  h := r.Health
+
+  fmt.Fprintf(&b, "Stats for %s mounted on %s:\n", r.Device, r.Mountpoint)
+  switch t := r.Transport.(type) {
+  case *NFSTransportCountersUDP:
+    fmt.Fprintf(&b, "\tTransport protocol: udp\n")
+    fmt.Fprintf(&b, "\tSource port: %d\n", t.Port)
+    fmt.Fprintf(&b, "\tBind count: %d\n", t.BindCount)
+    fmt.Fprintf(&b, "\tRPC requests: %d\n", t.RpcSends)
+    fmt.Fprintf(&b, "\tRPC replies: %d\n", t.RpcReceives)
+    fmt.Fprintf(&b, "\tXIDs not found: %d\n", t.BadXids)
+    writeXprtQueues(&b, h)
+  case *NFSTransportCountersTCP:
+    fmt.Fprintf(&b, "\tTransport protocol: tcp\n")
+    fmt.Fprintf(&b, "\tSource port: %d\n", t.Port)
+    fmt.Fprintf(&b, "\tBind count: %d\n", t.BindCount)
+    fmt.Fprintf(&b, "\tConnect count: %d\n", t.ConnectCount)
+    fmt.Fprintf(&b, "\tConnect time: %d seconds\n", t.ConnectTime)
+    fmt.Fprintf(&b, "\tIdle time: %d seconds\n", t.IdleTime)
+    fmt.Fprintf(&b, "\tRPC requests: %d\n", t.RpcSends)
+    fmt.Fprintf(&b, "\tRPC replies: %d\n", t.RpcReceives)
+    fmt.Fprintf(&b, "\tXIDs not found: %d\n", t.BadXids)
+    writeXprtQueues(&b, h)
+  case *NFSTransportCountersRDMA:
+    fmt.Fprintf(&b, "\tTransport protocol: rdma\n")
+    fmt.Fprintf(&b, "\tConnect count: %d\n", t.ConnectCount)
+    fmt.Fprintf(&b, "\tConnect time: %d seconds\n", t.ConnectTime)
+    fmt.Fprintf(&b, "\tIdle time: %d seconds\n", t.IdleTime)
+    fmt.Fprintf(&b, "\tRPC requests: %d\n", t.RpcSends)
+    fmt.Fprintf(&b, "\tRPC replies: %d\n", t.RpcReceives)
+    fmt.Fprintf(&b, "\tXIDs not found: %d\n", t.BadXids)
+    writeXprtQueues(&b, h)
+    fmt.Fprintf(&b, "\tRead segments: %d\n", t.ReadChunks)
+    fmt.Fprintf(&b, "\tWrite segments: %d\n", t.WriteChunks)
+    fmt.Fprintf(&b, "\tReply segments: %d\n", t.ReplyChunks)
+    fmt.Fprintf(&b, "\tRegistered: %d bytes\n", t.TotalRdmaReq)
+    fmt.Fprintf(&b, "\tRDMA received: %d bytes\n", t.TotalRdmaRep)
+    fmt.Fprintf(&b, "\tTotal pull-up: %d bytes\n", t.Pullup)
+    fmt.Fprintf(&b, "\tTotal fix-up: %d bytes\n", t.Fixup)
+    fmt.Fprintf(&b, "\tHardway allocations: %d bytes\n", t.Hardway)
+    fmt.Fprintf(&b, "\tFailed marshals: %d\n", t.FailedMarshal)
+    fmt.Fprintf(&b, "\tBad replies: %d\n", t.BadReply)
+  default:
+    fmt.Fprintf(&b, "\tno transport statistics\n")
+  }
+
+  return b.String()
+}
+
+// writeXprtQueues writes the slots and the average queue lengths of the
+// --xprt report, the ones the transport has.
+func writeXprtQueues(b *strings.Builder, h TransportHealth) {
+  if h.HasSlotStats {
+    fmt.Fprintf(b, "\tMax slots: %d\n", h.MaxRPCSlots)
+  }
+  if h.Sends == 0 {
+    return
+  }
+  fmt.Fprintf(b, "\tAvg backlog length: %d\n", int64(h.AvgBacklog))
+  if h.HasSlotStats {
+    fmt.Fprintf(b, "\tAvg send queue length: %d\n", int64(h.AvgSendingQueue))
+    fmt.Fprintf(b, "\tAvg pending queue length: %d\n", int64(h.AvgPendingQueue))
+  }
+}
+
+// formatTimedelta formats a duration like python's datetime.timedelta does,
+// which is what mountstats uses for the mount age, eg: `33 days, 18:51:58`.
+func formatTimedelta(d time.Duration) string {
+  total := int64(d / time.Second)
+  days := total / 86400
+  hms := fmt.Sprintf("%d:%02d:%02d", (total%86400)/3600, (total%3600)/60, total%60)
+
+  switch days {
+  case 0:
+    return hms
+  case 1:
+    return "1 day, " + hms
+  }
+
+  return fmt.Sprintf("%d days, %s", days, hms)
+}
@@ -0,0 +1,149 @@
+package nfsmountstats_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNFSReport(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  report := findDevice(t, mounts, "/mnt/nfs1/docs").NFSReport()
+  assert.Equal(t, 258103*time.Second, report.Age)
+  assert.True(t, strings.HasPrefix(report.Options, "rw,vers=4.2,"))
+  assert.Equal(t, []string{"caps=0xfffbc0b7", "wtmult=512", "dtsize=1048576", "bsize=0", "namlen=255"}, report.Capabilities)
+  assert.Contains(t, report.NFSv4Flags, "sessions")
+  assert.Equal(t, int64(1), report.SecFlavor)
+  assert.True(t, report.HasPseudoFlavor)
+
+  text := report.Text()
+  assert.True(t, strings.HasPrefix(text, "Stats for 10.0.2.31:/volume1/Public/docs mounted on /mnt/nfs1/docs:\n"))
+  assert.Contains(t, text, "  NFS mount age: 2 days, 23:41:43\n")
+  assert.Contains(t, text, "  NFS security flavor: 1  pseudoflavor: 1\n")
+  assert.Contains(t, text, "  data cache invalidated 513 times\n")
+  assert.Contains(t, text, "  VFS called nfs_file_open() 9263 times\n")
+  assert.Contains(t, text, "  client read 11208171 bytes via NFS READ\n")
+}
+
+func TestRPCReport(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // xprt: tcp 0 0 62 0 0 35130 35097 3 889722 0 31 11242 11142
+  report := findDevice(t, mounts, "/mnt/nfs1/docs").RPCReport()
+  assert.Equal(t, uint64(35130), report.Sends)
+  assert.Equal(t, uint64(35097), report.Receives)
+  assert.Equal(t, uint64(3), report.BadXids)
+
+  // ops come out in kernel order, and the ones that never ran are left out
+  assert.Equal(t, "NULL", report.Ops[0].Op)
+  assert.Equal(t, "READ", report.Ops[1].Op)
+  for _, op := range report.Ops {
+    assert.NotZero(t, op.Operations, op.Op)
+  }
+
+  // GETATTR: 13920 13924 0 3187904 3394844 6668 27030 34563 7
+  var getattr nfsmountstats.RPCOpReport
+  for _, op := range report.Ops {
+    if op.Op == "GETATTR" {
+      getattr = op
+    }
+  }
+  assert.Equal(t, uint64(4), getattr.Retrans)
+  assert.Equal(t, uint64(7), getattr.Errors)
+  assert.InDelta(t, 39.6242, getattr.OpsPercent, 0.0001)
+
+  text := report.Text()
+  assert.Contains(t, text, "  35130 RPC requests sent, 35097 RPC replies received (3 XIDs not found)\n")
+  assert.Contains(t, text, "GETATTR:\n"+
+    "\t13920 ops (39%) \t4 retrans (0%) \t0 major timeouts\t7 errors (0%)\n"+
+    "\tavg bytes sent per op: 229\tavg bytes received per op: 243\n"+
+    "\tbacklog wait: 0.479023 \tRTT: 1.941810 \ttotal execute time: 2.482974 (milliseconds)\n")
+  // an op without retrans or errors keeps the trailing space after its ops
+  assert.Regexp(t, "\nNULL:\n\t[0-9]+ ops \\([0-9]+%\\) \n", text)
+  assert.NotContains(t, text, "READLINK:")
+}
+
+func TestReportsInterval(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    dev.NFSInfo.Events.VfsOpen += 20
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations += 40
+    read.Transmissions += 40
+    read.CumRespTime += 100
+    dev.NFSInfo.RPCOpStats["READ"] = read
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 40
+    xprt.RpcReceives += 40
+  }).GetMountMap()["/mailhome6"]
+
+  nfs := delta.NFSReport()
+  assert.Equal(t, 10*time.Second, nfs.Age)
+  assert.Equal(t, uint64(20), nfs.Events.VfsOpen)
+  assert.Contains(t, nfs.Text(), "  NFS mount age: 0:00:10\n")
+
+  rpc := delta.RPCReport()
+  assert.Equal(t, uint64(40), rpc.Sends)
+  assert.Len(t, rpc.Ops, 1)
+  assert.Equal(t, "READ", rpc.Ops[0].Op)
+  assert.InDelta(t, 100.0, rpc.Ops[0].OpsPercent, 0.0001)
+  assert.InDelta(t, 2.5, rpc.Ops[0].AvgRTT, 0.0001)
+
+  xprtText := delta.XprtReport().Text()
+  assert.Contains(t, xprtText, "\tTransport protocol: tcp\n")
+  assert.Contains(t, xprtText, "\tRPC requests: 40\n")
+}
+
+func TestXprtReport(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // xprt: tcp 0 0 62 0 0 35130 35097 3 889722 0 31 11242 11142
+  tcp := findDevice(t, mounts, "/mnt/nfs1/docs").XprtReport().Text()
+  assert.Equal(t, "Stats for 10.0.2.31:/volume1/Public/docs mounted on /mnt/nfs1/docs:\n"+
+    "\tTransport protocol: tcp\n"+
+    "\tSource port: 0\n"+
+    "\tBind count: 0\n"+
+    "\tConnect count: 62\n"+
+    "\tConnect time: 0 seconds\n"+
+    "\tIdle time: 0 seconds\n"+
+    "\tRPC requests: 35130\n"+
+    "\tRPC replies: 35097\n"+
+    "\tXIDs not found: 3\n"+
+    "\tMax slots: 31\n"+
+    "\tAvg backlog length: 0\n"+
+    "\tAvg send queue length: 0\n"+
+    "\tAvg pending queue length: 0\n", tcp)
+
+  // xprt: udp 840 1 1013715537 1013715535 2 18247684089 0
+  udp := findDevice(t, mounts, "/mailhome5udp").XprtReport().Text()
+  assert.Contains(t, udp, "\tTransport protocol: udp\n\tSource port: 840\n\tBind count: 1\n")
+  assert.Contains(t, udp, "\tXIDs not found: 2\n\tAvg backlog length: 0\n")
+  assert.NotContains(t, udp, "Connect count")
+  assert.NotContains(t, udp, "Max slots")
+
+  rdma := findDevice(t, mounts, "/mailhome5rdma").XprtReport().Text()
+  assert.Contains(t, rdma, "\tTransport protocol: rdma\n")
+  assert.Contains(t, rdma, "\tRead segments: 101371553\n")
+  assert.Contains(t, rdma, "\tBad replies: 0\n")
+}
+
+func TestXprtReportQueueAverages(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    xprt := findDevice(t, cur, "/mnt/nfs1/docs").NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 10
+    xprt.BacklogUtil += 25
+    xprt.CumSendingQueue += 10
+    xprt.CumPendingQueue += 47
+  })
+
+  text := delta.GetMountMap()["/mnt/nfs1/docs"].XprtReport().Text()
+  assert.Contains(t, text, "\tRPC requests: 10\n")
+  assert.Contains(t, text, "\tAvg backlog length: 2\n")
+  assert.Contains(t, text, "\tAvg send queue length: 1\n")
+  assert.Contains(t, text, "\tAvg pending queue length: 4\n")
+}
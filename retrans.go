@@ -0,0 +1,131 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"sort"
+)
+
+// IDs of the findings made by RetransDetector
+const (
+  FindingRetrans      = "retrans"       // an op is being retransmitted more than it should
+  FindingMajorTimeout = "major-timeout" // an op hit a major timeout, the kernel logged "server not responding"
+  FindingSoftTimeout  = "soft-timeout"  // a soft mount hit a major timeout, applications got EIO
+  FindingSoftRetrans  = "soft-retrans"  // a soft mount is retransmitting, it's at risk of EIO
+)
+
+// RetransDetector flags mounts on flaky network paths from their per-op
+// retransmissions and major timeouts over an interval.
+// Retransmissions are Transmissions beyond Operations. A major timeout is
+// what happens when an op has been retransmitted `retrans` times without a
+// reply: a hard mount logs "server not responding" and keeps trying, while a
+// soft (or softerr) mount gives up and returns an error to the application.
+type RetransDetector struct {
+  WarnPercent     float64 // retransmissions as a percentage of ops that warn
+  CriticalPercent float64 // retransmissions as a percentage of ops that are critical
+  MinOps          uint64  // ops with fewer operations in the interval are ignored for percentages
+}
+
+// NewRetransDetector constructs a RetransDetector with the defaults, warning at
+// 1% retransmissions and critical at 5%, for ops that ran at least 10 times.
+func NewRetransDetector() *RetransDetector {
+  return &RetransDetector{
+    WarnPercent: 1,
+    CriticalPercent: 5,
+    MinOps: 10,
+  }
+}
+
+// Check runs the detector over every device of the delta. Devices that are
+// New or were remounted are skipped, their counters are since mount.
+func (r *RetransDetector) Check(delta *MountstatsDelta) []Finding {
+  var findings []Finding
+  for idx := range delta.Devices {
+    if delta.Devices[idx].sinceMount() {
+      continue
+    }
+    findings = append(findings, r.CheckDevice(&delta.Devices[idx])...)
+  }
+  SortFindings(findings)
+
+  return findings
+}
+
+// CheckDevice runs the detector over a single device delta. Counters are only
+// meaningful as a change, so this shouldn't be given a New or remounted
+// device's delta unless flagging everything since mount is what's wanted.
+func (r *RetransDetector) CheckDevice(d *MountDeviceDelta) []Finding {
+  soft := d.NFSInfo.Options.Has("soft") || d.NFSInfo.Options.Has("softerr")
+
+  var findings []Finding
+  var softRetrans uint64
+  ops := make([]string, 0, len(d.NFSInfo.RPCOpStats))
+  for op := range d.NFSInfo.RPCOpStats {
+    ops = append(ops, op)
+  }
+  sort.Strings(ops)
+
+  for _, op := range ops {
+    s := d.NFSInfo.RPCOpStats[op]
+    finding := Finding{Device: d.Device, Mountpoint: d.Mountpoint, Op: op}
+
+    if s.MajorTimeouts > 0 {
+      finding.Value = float64(s.MajorTimeouts)
+      if soft {
+        finding.ID = FindingSoftTimeout
+        finding.Severity = SeverityCritical
+        finding.Message = fmt.Sprintf("%s had %d major timeouts on a soft mount, applications got EIO", op, s.MajorTimeouts)
+      } else {
+        finding.ID = FindingMajorTimeout
+        finding.Severity = SeverityWarning
+        finding.Message = fmt.Sprintf("%s had %d major timeouts, the server stopped responding", op, s.MajorTimeouts)
+      }
+      findings = append(findings, finding)
+    }
+
+    if s.Transmissions <= s.Operations {
+      continue
+    }
+    retrans := s.Transmissions - s.Operations
+    softRetrans += retrans
+    if s.Operations < r.MinOps {
+      continue
+    }
+
+    percent := float64(retrans) * 100 / float64(s.Operations)
+    finding.ID = FindingRetrans
+    finding.Value = percent
+    switch {
+    case percent >= r.CriticalPercent:
+      finding.Severity = SeverityCritical
+      finding.Threshold = r.CriticalPercent
+    case percent >= r.WarnPercent:
+      finding.Severity = SeverityWarning
+      finding.Threshold = r.WarnPercent
+    default:
+      continue
+    }
+    finding.Message = fmt.Sprintf("%s retransmitted %.2f%% of requests (%d of %d)", op, percent, retrans, s.Operations)
+    findings = append(findings, finding)
+  }
+
+  // any retransmission on a soft mount is a step towards a major timeout, and
+  // so towards an application seeing EIO
+  if soft && softRetrans > 0 {
+    finding := Finding{
+      ID: FindingSoftRetrans,
+      Severity: SeverityWarning,
+      Device: d.Device,
+      Mountpoint: d.Mountpoint,
+      Value: float64(softRetrans),
+    }
+    if retrans, ok := d.NFSInfo.Options.Uint("retrans"); ok {
+      finding.Threshold = float64(retrans)
+      finding.Message = fmt.Sprintf("soft mount retransmitted %d requests, any that's retransmitted %d times returns EIO", softRetrans, retrans)
+    } else {
+      finding.Message = fmt.Sprintf("soft mount retransmitted %d requests, it's at risk of returning EIO", softRetrans)
+    }
+    findings = append(findings, finding)
+  }
+
+  return findings
+}
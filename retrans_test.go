@@ -0,0 +1,96 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+// retransDelta returns the delta of /mailhome6 over 10s where `op` ran `ops`
+// times with `transmissions` and `timeouts`, optionally as a soft mount.
+func retransDelta(t *testing.T, op string, ops, transmissions, timeouts uint64, soft bool) *nfsmountstats.MountstatsDelta {
+  t.Helper()
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    s := dev.NFSInfo.RPCOpStats[op]
+    s.Operations += ops
+    s.Transmissions += transmissions
+    s.MajorTimeouts += timeouts
+    dev.NFSInfo.RPCOpStats[op] = s
+    if soft {
+      delete(dev.NFSInfo.Options, "hard")
+      dev.NFSInfo.Options["soft"] = ""
+    }
+  })
+
+  return delta
+}
+
+func TestRetransDetector(t *testing.T) {
+  detector := nfsmountstats.NewRetransDetector()
+
+  // nothing going on in the testdata itself
+  assert.Empty(t, detector.Check(retransDelta(t, "READ", 0, 0, 0, false)))
+
+  // 2% is a warning
+  findings := detector.Check(retransDelta(t, "READ", 1000, 1020, 0, false))
+  assert.Len(t, findings, 1)
+  assert.Equal(t, nfsmountstats.FindingRetrans, findings[0].ID)
+  assert.Equal(t, nfsmountstats.SeverityWarning, findings[0].Severity)
+  assert.Equal(t, "/mailhome6", findings[0].Mountpoint)
+  assert.Equal(t, "READ", findings[0].Op)
+  assert.InDelta(t, 2.0, findings[0].Value, 0.0001)
+  assert.Equal(t, 1.0, findings[0].Threshold)
+
+  // 10% is critical
+  findings = detector.Check(retransDelta(t, "WRITE", 100, 110, 0, false))
+  assert.Len(t, findings, 1)
+  assert.Equal(t, nfsmountstats.SeverityCritical, findings[0].Severity)
+  assert.Equal(t, 5.0, findings[0].Threshold)
+
+  // too few ops to judge a percentage on
+  assert.Empty(t, detector.Check(retransDelta(t, "WRITE", 5, 7, 0, false)))
+}
+
+func TestRetransDetectorMajorTimeouts(t *testing.T) {
+  detector := nfsmountstats.NewRetransDetector()
+
+  // a hard mount just keeps going
+  findings := detector.Check(retransDelta(t, "GETATTR", 1, 3, 1, false))
+  assert.Len(t, findings, 1)
+  assert.Equal(t, nfsmountstats.FindingMajorTimeout, findings[0].ID)
+  assert.Equal(t, nfsmountstats.SeverityWarning, findings[0].Severity)
+
+  // a soft mount returned EIO, and is still retransmitting
+  findings = detector.Check(retransDelta(t, "GETATTR", 1, 3, 1, true))
+  assert.Len(t, findings, 2)
+  assert.Equal(t, nfsmountstats.FindingSoftTimeout, findings[0].ID)
+  assert.Equal(t, nfsmountstats.SeverityCritical, findings[0].Severity)
+  assert.Equal(t, nfsmountstats.FindingSoftRetrans, findings[1].ID)
+  assert.Equal(t, 2.0, findings[1].Value)
+  // retrans=2 in the mount options
+  assert.Equal(t, 2.0, findings[1].Threshold)
+}
+
+func TestRetransDetectorSkipsNewMounts(t *testing.T) {
+  detector := nfsmountstats.NewRetransDetector()
+
+  // a soft mount made between the snapshots, with major timeouts from long ago
+  prev, cur := loadTestSnapshots(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    delete(dev.NFSInfo.Options, "hard")
+    dev.NFSInfo.Options["soft"] = ""
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.MajorTimeouts += 5
+    dev.NFSInfo.RPCOpStats["READ"] = read
+  })
+  removeDevice(t, prev, "/mailhome6")
+
+  delta := cur.Delta(prev)
+  assert.Empty(t, detector.Check(delta))
+  // asking for the device on its own still flags everything since mount
+  assert.NotEmpty(t, detector.CheckDevice(delta.GetMountMap()["/mailhome6"]))
+}
@@ -0,0 +1,231 @@
+package nfsmountstats
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Source produces a new Mountstats snapshot every time it's called, eg:
+// NewMountstats, or a closure around NewNamespaceMountstats for another
+// namespace.
+type Source func() (*Mountstats, error)
+
+// BackpressurePolicy decides what a Sampler started with Start does when its
+// channel is full because the consumer isn't keeping up.
+type BackpressurePolicy int
+
+const (
+  // BackpressureBlock waits for the consumer. Sampling stops while blocked,
+  // the ticks that pass are counted as missed and the next delta covers them.
+  BackpressureBlock BackpressurePolicy = iota
+  // BackpressureDropNewest throws away the new sample and keeps sampling.
+  BackpressureDropNewest
+  // BackpressureDropOldest throws away the oldest queued sample to make room
+  // for the new one, so the consumer always sees the most recent data.
+  BackpressureDropOldest
+)
+
+// Sample is one poll of a Sampler's Source.
+type Sample struct {
+  Tick     int              // index of the tick this sample was taken on, starting at 0
+  Missed   int              // ticks skipped since the previous sample because it ran late
+  Snapshot *Mountstats      // nil if Err is set
+  Delta    *MountstatsDelta // change since the previous good snapshot, nil for the first one
+  Err      error            // the Source failed, the next Delta covers this tick too
+}
+
+// SamplerStats counts what a Sampler has done so far.
+type SamplerStats struct {
+  Samples uint64 // ticks the Source was polled on
+  Errors  uint64 // polls where the Source returned an error
+  Missed  uint64 // ticks skipped because sampling or delivery ran late
+  Dropped uint64 // samples thrown away by the BackpressurePolicy
+}
+
+// Sampler polls a Source on a fixed Interval and hands out every snapshot
+// along with its Delta from the previous one, so long running agents don't
+// each need to write their own polling loop.
+// Ticks are scheduled from the time Run starts rather than from the end of the
+// previous sample, so a slow Source or consumer doesn't make the schedule
+// drift; any tick that's already passed is skipped and counted as missed.
+type Sampler struct {
+  Source       Source        // what to poll, NewMountstats by default
+  Interval     time.Duration // time between ticks
+  Jitter       time.Duration // up to this much random delay is added to each tick, must be less than Interval
+  Buffer       int           // size of the channel returned by Start, at least 1 when dropping
+  Backpressure BackpressurePolicy
+
+  mu    sync.Mutex
+  stats SamplerStats
+}
+
+// NewSampler constructs a Sampler that polls NewMountstats every `interval`,
+// blocking when the consumer falls behind.
+func NewSampler(interval time.Duration) *Sampler {
+  return &Sampler{
+    Source: NewMountstats,
+    Interval: interval,
+  }
+}
+
+// Stats returns a copy of the Sampler's counters, it's safe to call while the
+// Sampler is running.
+func (s *Sampler) Stats() SamplerStats {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  return s.stats
+}
+
+// Start runs the Sampler in a new goroutine, delivering samples on the returned
+// channel according to the Backpressure policy. The channel is closed once
+// `ctx` is done.
+// Returns an error, and no channel, if the Sampler isn't configured correctly.
+func (s *Sampler) Start(ctx context.Context) (<-chan Sample, error) {
+  if err := s.validate(); err != nil {
+    return nil, err
+  }
+
+  // the drop policies need somewhere to drop from
+  buffer := s.Buffer
+  if s.Backpressure != BackpressureBlock && buffer < 1 {
+    buffer = 1
+  }
+  samples := make(chan Sample, buffer)
+  go func() {
+    defer close(samples)
+    s.Run(ctx, func(sample Sample) {
+      s.deliver(ctx, samples, sample)
+    })
+  }()
+
+  return samples, nil
+}
+
+// Run polls the Source, calling `fn` with every sample, until `ctx` is done.
+// The first sample is taken right away. `fn` is called on the same goroutine,
+// so a slow callback delays sampling and the ticks it overruns are missed.
+// Returns the context's error once it's done, or an error straight away if
+// the Sampler isn't configured correctly.
+func (s *Sampler) Run(ctx context.Context, fn func(Sample)) error {
+  if err := s.validate(); err != nil {
+    return err
+  }
+  source := s.Source
+  if source == nil {
+    source = NewMountstats
+  }
+
+  var prev *Mountstats
+  start := time.Now()
+  tick, missed := 0, 0
+  for {
+    if err := ctx.Err(); err != nil {
+      return err
+    }
+
+    sample := Sample{Tick: tick, Missed: missed}
+    snapshot, err := source()
+    if err != nil {
+      sample.Err = err
+    } else {
+      sample.Snapshot = snapshot
+      if prev != nil {
+        sample.Delta = snapshot.Delta(prev)
+      }
+      prev = snapshot
+    }
+    s.count(func(stats *SamplerStats) {
+      stats.Samples++
+      if err != nil {
+        stats.Errors++
+      }
+    })
+    fn(sample)
+
+    // work out the next tick that's still in the future, anything between
+    // here and there was missed
+    now := time.Now()
+    next := int(now.Sub(start)/s.Interval) + 1
+    if next <= tick {
+      next = tick + 1
+    }
+    missed = next - tick - 1
+    if missed > 0 {
+      s.count(func(stats *SamplerStats) {
+        stats.Missed += uint64(missed)
+      })
+    }
+    tick = next
+
+    due := start.Add(time.Duration(tick) * s.Interval)
+    if s.Jitter > 0 {
+      due = due.Add(rand.N(s.Jitter))
+    }
+    timer := time.NewTimer(due.Sub(now))
+    select {
+    case <-ctx.Done():
+      timer.Stop()
+      return ctx.Err()
+    case <-timer.C:
+    }
+  }
+}
+
+// validate checks the Sampler is configured correctly.
+func (s *Sampler) validate() error {
+  if s.Interval <= 0 {
+    return errors.New("sampler interval must be positive")
+  }
+  if s.Jitter < 0 || s.Jitter >= s.Interval {
+    return errors.New("sampler jitter can't be negative or as long as the interval")
+  }
+
+  return nil
+}
+
+// deliver sends a sample on the channel, applying the Backpressure policy when
+// it's full.
+func (s *Sampler) deliver(ctx context.Context, samples chan Sample, sample Sample) {
+  switch s.Backpressure {
+  case BackpressureDropNewest:
+    select {
+    case samples <- sample:
+    default:
+      s.count(func(stats *SamplerStats) {
+        stats.Dropped++
+      })
+    }
+  case BackpressureDropOldest:
+    for {
+      select {
+      case samples <- sample:
+        return
+      default:
+      }
+      // the consumer may have taken one in the meantime, which is just as good
+      select {
+      case <-samples:
+        s.count(func(stats *SamplerStats) {
+          stats.Dropped++
+        })
+      default:
+      }
+    }
+  default:
+    select {
+    case samples <- sample:
+    case <-ctx.Done():
+    }
+  }
+}
+
+// count updates the stats under the lock.
+func (s *Sampler) count(fn func(*SamplerStats)) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  fn(&s.stats)
+}
@@ -0,0 +1,157 @@
+package nfsmountstats_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSource returns a Source that serves the testdata snapshot with the
+// READ count of /mailhome6 going up by 10 on every call, and fails on the
+// calls listed in `failOn`.
+func testSource(t *testing.T, failOn ...int) nfsmountstats.Source {
+  base := loadTestMountstats(t)
+  var calls atomic.Int64
+
+  return func() (*nfsmountstats.Mountstats, error) {
+    call := int(calls.Add(1)) - 1
+    for _, fail := range failOn {
+      if call == fail {
+        return nil, errors.New("source failed")
+      }
+    }
+
+    mounts := loadTestMountstats(t)
+    mounts.Timestamp = time.Now()
+    dev := findDevice(t, mounts, "/mailhome6")
+    read := findDevice(t, base, "/mailhome6").NFSInfo.RPCOpStats["READ"]
+    read.Operations += uint64(call * 10)
+    dev.NFSInfo.RPCOpStats["READ"] = read
+
+    return mounts, nil
+  }
+}
+
+func TestSamplerStart(t *testing.T) {
+  source := testSource(t, 2)
+  sampler := nfsmountstats.NewSampler(10 * time.Millisecond)
+  sampler.Source = source
+
+  ctx, cancel := context.WithCancel(context.Background())
+  samples, err := sampler.Start(ctx)
+  if err != nil {
+    t.Fatalf("couldn't start the sampler: %v", err)
+  }
+
+  first := <-samples
+  assert.Equal(t, 0, first.Tick)
+  assert.NoError(t, first.Err)
+  assert.NotNil(t, first.Snapshot)
+  assert.Nil(t, first.Delta)
+
+  second := <-samples
+  assert.NoError(t, second.Err)
+  assert.Equal(t, uint64(10), second.Delta.GetMountMap()["/mailhome6"].NFSInfo.RPCOpStats["READ"].Operations)
+
+  // a failed poll doesn't lose any counts, the next delta covers it
+  third := <-samples
+  assert.Error(t, third.Err)
+  assert.Nil(t, third.Snapshot)
+  fourth := <-samples
+  assert.NoError(t, fourth.Err)
+  assert.Equal(t, uint64(20), fourth.Delta.GetMountMap()["/mailhome6"].NFSInfo.RPCOpStats["READ"].Operations)
+
+  cancel()
+  for range samples {
+  }
+  stats := sampler.Stats()
+  assert.GreaterOrEqual(t, stats.Samples, uint64(4))
+  assert.Equal(t, uint64(1), stats.Errors)
+}
+
+func TestSamplerRunMissedTicks(t *testing.T) {
+  source := testSource(t)
+  sampler := nfsmountstats.NewSampler(10 * time.Millisecond)
+  sampler.Source = source
+
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  var samples []nfsmountstats.Sample
+  err := sampler.Run(ctx, func(sample nfsmountstats.Sample) {
+    samples = append(samples, sample)
+    if len(samples) == 1 {
+      // overrun a few ticks
+      time.Sleep(35 * time.Millisecond)
+    }
+    if len(samples) == 2 {
+      cancel()
+    }
+  })
+
+  assert.ErrorIs(t, err, context.Canceled)
+  assert.Len(t, samples, 2)
+  assert.GreaterOrEqual(t, samples[1].Missed, 2)
+  assert.Equal(t, samples[1].Missed+1, samples[1].Tick)
+  assert.Equal(t, uint64(samples[1].Missed), sampler.Stats().Missed)
+}
+
+func TestSamplerDropNewest(t *testing.T) {
+  source := testSource(t)
+  sampler := nfsmountstats.NewSampler(5 * time.Millisecond)
+  sampler.Source = source
+  sampler.Backpressure = nfsmountstats.BackpressureDropNewest
+  sampler.Buffer = 1
+
+  ctx, cancel := context.WithCancel(context.Background())
+  samples, err := sampler.Start(ctx)
+  if err != nil {
+    t.Fatalf("couldn't start the sampler: %v", err)
+  }
+  // nobody reads while the buffer fills up
+  time.Sleep(50 * time.Millisecond)
+  cancel()
+
+  first := <-samples
+  assert.Equal(t, 0, first.Tick)
+  for range samples {
+  }
+  assert.Greater(t, sampler.Stats().Dropped, uint64(0))
+}
+
+func TestSamplerDropOldest(t *testing.T) {
+  source := testSource(t)
+  sampler := nfsmountstats.NewSampler(5 * time.Millisecond)
+  sampler.Source = source
+  sampler.Backpressure = nfsmountstats.BackpressureDropOldest
+
+  ctx, cancel := context.WithCancel(context.Background())
+  samples, err := sampler.Start(ctx)
+  if err != nil {
+    t.Fatalf("couldn't start the sampler: %v", err)
+  }
+  time.Sleep(50 * time.Millisecond)
+  cancel()
+
+  // the queued sample is a recent one, not the first
+  latest := <-samples
+  assert.Greater(t, latest.Tick, 0)
+  assert.Greater(t, sampler.Stats().Dropped, uint64(0))
+}
+
+func TestSamplerConfigErrors(t *testing.T) {
+  sampler := nfsmountstats.NewSampler(0)
+  assert.Error(t, sampler.Run(context.Background(), func(nfsmountstats.Sample) {}))
+
+  sampler = nfsmountstats.NewSampler(time.Second)
+  sampler.Jitter = time.Second
+  assert.Error(t, sampler.Run(context.Background(), func(nfsmountstats.Sample) {}))
+
+  samples, err := sampler.Start(context.Background())
+  assert.Error(t, err)
+  assert.Nil(t, samples)
+}
@@ -0,0 +1,233 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// SharedMounts records which NFS mounts are really the same kernel object.
+// Mounts of one superblock (bind mounts, or several subdirectories of an export
+// sharing an nfs_client) all report the superblock's counters, and mounts over
+// one transport all report its xprt: counters, so summing across mounts counts
+// them once for every mount. Every mount is in exactly one superblock group and,
+// if it has an xprt: line, one transport group.
+type SharedMounts struct {
+  Superblocks []SharedGroup
+  Transports  []SharedGroup
+}
+
+// SharedGroup is a set of mounts sharing one superblock or transport.
+type SharedGroup struct {
+  Key         string   // the mountinfo device id or TransportKey, or a fingerprint of the shared counters
+  ByDeviceID  bool     // grouped on the device id, which is certain, rather than on counters
+  Mountpoints []string // sorted
+}
+
+// Totals sums the counters of every NFS mount, counting each superblock and
+// each transport once.
+type Totals struct {
+  Mounts      int
+  Superblocks int
+  Transports  int
+  Events      NFSEventCounters
+  Bytes       NFSByteCounters
+  RPCOpStats  map[string]RPCOpStat
+  Sends       uint64 // rpc requests sent, from the transports
+  Receives    uint64
+  BadXids     uint64
+}
+
+// Shared works out which NFS mounts share a superblock or a transport.
+// Superblocks are matched on the device id of the mount in `mountinfo` (see
+// NewMountinfo) when it's there, and otherwise on having the same transport
+// and identical age and per-op counters, which distinct superblocks practically
+// never do. The kernel reads the counters again for every mount while the file
+// is being generated, so on a busy client mounts of one superblock can miss
+// each other and be counted more than once, pass `mountinfo` to avoid that.
+// Transports are matched on their TransportKey alone, the xprt: counters drift
+// the same way.
+// `mountinfo` may be nil, in which case only counters are compared. Mounts are
+// matched to mountinfo on their mountpoint and source, in order of appearance
+// when the same export is stacked more than once on a path.
+func (m *Mountstats) Shared(mountinfo []MountInfo) SharedMounts {
+  // both files list mounts in the same order, so stacked mounts of the same
+  // export on the same path are matched in order of appearance
+  deviceIDs := make(map[string][]string)
+  for _, mount := range mountinfo {
+    key := mount.Source + " " + mount.Mountpoint
+    deviceIDs[key] = append(deviceIDs[key], mount.DeviceID)
+  }
+
+  superblocks := newSharedGroups()
+  transports := newSharedGroups()
+  for _, dev := range m.GetNFSDevices() {
+    key := dev.Device + " " + dev.Mountpoint
+    if ids := deviceIDs[key]; len(ids) > 0 {
+      deviceIDs[key] = ids[1:]
+      superblocks.add("dev:"+ids[0], true, dev.Mountpoint)
+    } else {
+      superblocks.add(superblockFingerprint(dev), false, dev.Mountpoint)
+    }
+
+    if dev.NFSInfo.Transport != nil {
+      transports.add(dev.TransportKey(), false, dev.Mountpoint)
+    }
+  }
+
+  return SharedMounts{
+    Superblocks: superblocks.sorted(),
+    Transports: transports.sorted(),
+  }
+}
+
+// Totals sums the counters since mount of every NFS mount, counting every
+// group in `shared` once. Mounts that aren't in `shared` count on their own.
+func (m *Mountstats) Totals(shared SharedMounts) Totals {
+  var members []groupMember
+  for _, dev := range m.GetNFSDevices() {
+    members = append(members, groupMember{dev.Device, dev.Mountpoint, dev.MountType, &dev.NFSInfo})
+  }
+
+  return sharedTotals(shared, members)
+}
+
+// Totals sums the counters of every device delta, counting every group in
+// `shared` once. `shared` should come from the newer of the two snapshots.
+func (d *MountstatsDelta) Totals(shared SharedMounts) Totals {
+  var members []groupMember
+  for idx := range d.Devices {
+    dev := &d.Devices[idx]
+    members = append(members, groupMember{dev.Device, dev.Mountpoint, dev.MountType, &dev.NFSInfo})
+  }
+
+  return sharedTotals(shared, members)
+}
+
+// sharedTotals sums one member of each shared group.
+func sharedTotals(shared SharedMounts, members []groupMember) Totals {
+  totals := Totals{
+    Mounts: len(members),
+    RPCOpStats: make(map[string]RPCOpStat),
+  }
+
+  // which group every mountpoint belongs to, mounts in no group are their own
+  superblockOf := sharedGroupIndex(shared.Superblocks)
+  transportOf := sharedGroupIndex(shared.Transports)
+  countedSuperblocks := make(map[string]bool)
+  countedTransports := make(map[string]bool)
+
+  for _, member := range members {
+    superblock, ok := superblockOf[member.mountpoint]
+    if !ok {
+      superblock = "mount:" + member.mountpoint
+    }
+    if !countedSuperblocks[superblock] {
+      countedSuperblocks[superblock] = true
+      totals.Superblocks++
+      totals.Events = totals.Events.add(member.info.Events)
+      totals.Bytes = totals.Bytes.add(member.info.Bytes)
+      for op, s := range member.info.RPCOpStats {
+        totals.RPCOpStats[op] = totals.RPCOpStats[op].add(s)
+      }
+    }
+
+    if member.info.Transport == nil {
+      continue
+    }
+    transport, ok := transportOf[member.mountpoint]
+    if !ok {
+      transport = "mount:" + member.mountpoint
+    }
+    if !countedTransports[transport] {
+      countedTransports[transport] = true
+      totals.Transports++
+      health := newTransportHealth(member.info.Transport, 0, false)
+      totals.Sends += health.Sends
+      totals.Receives += health.Receives
+      totals.BadXids += health.BadXids
+    }
+  }
+
+  return totals
+}
+
+// sharedGroupIndex maps every mountpoint in `groups` to its group key.
+func sharedGroupIndex(groups []SharedGroup) map[string]string {
+  index := make(map[string]string)
+  for _, group := range groups {
+    for _, mountpoint := range group.Mountpoints {
+      index[mountpoint] = group.Key
+    }
+  }
+
+  return index
+}
+
+// superblockFingerprint hashes what a mount reports about its superblock, or
+// returns a key of its own for a mount that hasn't done anything to compare.
+// Only the per-op counters are used, the events: and bytes: lines of mounts
+// that share a superblock are often a few counts apart even when the per-op
+// lines aren't.
+func superblockFingerprint(dev *MountDevice) string {
+  info := &dev.NFSInfo
+  var b strings.Builder
+  fmt.Fprintf(&b, "%s|%d", dev.TransportKey(), info.Age)
+  ops := make([]string, 0, len(info.RPCOpStats))
+  var active bool
+  for op, s := range info.RPCOpStats {
+    ops = append(ops, op)
+    active = active || s.Operations > 0
+  }
+  if !active {
+    return "mount:" + dev.Mountpoint
+  }
+  sort.Strings(ops)
+  for _, op := range ops {
+    fmt.Fprintf(&b, "|%s %v", op, info.RPCOpStats[op])
+  }
+
+  return "counters:" + fingerprint(b.String())
+}
+
+// fingerprint returns a short hash of `s`.
+func fingerprint(s string) string {
+  h := fnv.New64a()
+  h.Write([]byte(s))
+
+  return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// sharedGroups builds SharedGroups up one mount at a time.
+type sharedGroups struct {
+  groups map[string]*SharedGroup
+}
+
+func newSharedGroups() *sharedGroups {
+  return &sharedGroups{groups: make(map[string]*SharedGroup)}
+}
+
+// add puts `mountpoint` in the group with `key`, creating it if needed.
+func (s *sharedGroups) add(key string, byDeviceID bool, mountpoint string) {
+  group, ok := s.groups[key]
+  if !ok {
+    group = &SharedGroup{Key: key, ByDeviceID: byDeviceID}
+    s.groups[key] = group
+  }
+  group.Mountpoints = append(group.Mountpoints, mountpoint)
+}
+
+// sorted returns the groups ordered on their first mountpoint.
+func (s *sharedGroups) sorted() []SharedGroup {
+  groups := make([]SharedGroup, 0, len(s.groups))
+  for _, group := range s.groups {
+    sort.Strings(group.Mountpoints)
+    groups = append(groups, *group)
+  }
+  sort.Slice(groups, func(i, j int) bool {
+    return groups[i].Mountpoints[0] < groups[j].Mountpoints[0]
+  })
+
+  return groups
+}
@@ -0,0 +1,151 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+// the four mounts of one superblock in the testdata
+var nfs1Mounts = []string{"/mnt/nfs1/code", "/mnt/nfs1/docs", "/mnt/nfs1/docs_work", "/mnt/nfs1/system_setup"}
+
+func loadTestMountinfo(t *testing.T) []nfsmountstats.MountInfo {
+  t.Helper()
+  mountinfo, err := nfsmountstats.NewMountinfo()
+  if err != nil {
+    t.Fatalf("couldn't read mountinfo: %v", err)
+  }
+
+  return mountinfo
+}
+
+func TestSharedFromMountinfo(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  shared := mounts.Shared(loadTestMountinfo(t))
+  assert.Len(t, shared.Superblocks, 7)
+  nfs1 := shared.Superblocks[5]
+  assert.Equal(t, "dev:0:53", nfs1.Key)
+  assert.True(t, nfs1.ByDeviceID)
+  assert.Equal(t, nfs1Mounts, nfs1.Mountpoints)
+
+  // /mailhome5 and /fakehomestatver1 have the same counters, but mountinfo
+  // knows they're different superblocks
+  assert.Equal(t, []string{"/fakehomestatver1"}, shared.Superblocks[0].Mountpoints)
+  assert.Equal(t, []string{"/mailhome5"}, shared.Superblocks[1].Mountpoints)
+}
+
+func TestSharedFromCounters(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // the events: and bytes: lines of the nfs1 mounts are a few counts apart
+  shared := mounts.Shared(nil)
+  assert.Len(t, shared.Superblocks, 7)
+  assert.False(t, shared.Superblocks[5].ByDeviceID)
+  assert.Equal(t, nfs1Mounts, shared.Superblocks[5].Mountpoints)
+  // /fakehomestatver1 was read a second after /mailhome5, so they're not merged
+  assert.Equal(t, []string{"/fakehomestatver1"}, shared.Superblocks[0].Mountpoints)
+
+  var transports [][]string
+  for _, group := range shared.Transports {
+    transports = append(transports, group.Mountpoints)
+  }
+  // /fakehomestatver1 prints fewer xprt: fields, but it's the same server and
+  // local port so it's the same transport
+  assert.Equal(t, [][]string{
+    {"/fakehomestatver1", "/mailhome5", "/mailhome6"},
+    {"/mailhome5rdma"},
+    {"/mailhome5udp"},
+    nfs1Mounts,
+    {"/webmail0"},
+  }, transports)
+  assert.Equal(t, "tcp 10.0.47.9 port 840", shared.Transports[0].Key)
+}
+
+func TestSharedCountersDrift(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // the counters of each mount are read while the file is generated, on a busy
+  // client a mount of the superblock can be a few ops ahead of the others
+  docs := findDevice(t, mounts, "/mnt/nfs1/docs")
+  getattr := docs.NFSInfo.RPCOpStats["GETATTR"]
+  getattr.Operations++
+  docs.NFSInfo.RPCOpStats["GETATTR"] = getattr
+  docs.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP).RpcSends++
+
+  // counters that aren't identical aren't guessed at, it's counted on its own
+  shared := mounts.Shared(nil)
+  assert.Len(t, shared.Superblocks, 8)
+  assert.Equal(t, nfs1Mounts, shared.Transports[3].Mountpoints)
+
+  // mountinfo doesn't depend on the counters
+  shared = mounts.Shared(loadTestMountinfo(t))
+  assert.Len(t, shared.Superblocks, 7)
+  assert.Equal(t, nfs1Mounts, shared.Superblocks[5].Mountpoints)
+}
+
+func TestSharedStackedMounts(t *testing.T) {
+  mounts := loadTestMountstats(t)
+  mountinfo := loadTestMountinfo(t)
+
+  // another export mounted over /mnt/nfs1/docs, mountstats lists both
+  over := *findDevice(t, mounts, "/mnt/nfs1/docs")
+  over.Device = "10.0.2.31:/volume1/Public/other"
+  mounts.Devices = append(mounts.Devices, over)
+  mountinfo = append(mountinfo, nfsmountstats.MountInfo{
+    MountID: 450,
+    ParentID: 310,
+    DeviceID: "0:70",
+    Root: "/volume1/Public/other",
+    Mountpoint: "/mnt/nfs1/docs",
+    FSType: "nfs4",
+    Source: "10.0.2.31:/volume1/Public/other",
+  })
+
+  shared := mounts.Shared(mountinfo)
+  assert.Len(t, shared.Superblocks, 8)
+  var keys []string
+  for _, group := range shared.Superblocks {
+    keys = append(keys, group.Key)
+  }
+  assert.Contains(t, keys, "dev:0:53")
+  assert.Contains(t, keys, "dev:0:70")
+}
+
+func TestTotals(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  totals := mounts.Totals(mounts.Shared(loadTestMountinfo(t)))
+  assert.Equal(t, 10, totals.Mounts)
+  assert.Equal(t, 7, totals.Superblocks)
+  assert.Equal(t, 5, totals.Transports)
+  assert.Equal(t, uint64(4125517993), totals.Sends)
+
+  // nothing shared, every mount counts on its own
+  naive := mounts.Totals(nfsmountstats.SharedMounts{})
+  assert.Equal(t, 10, naive.Superblocks)
+  assert.Equal(t, 10, naive.Transports)
+  // GETATTR: 13920 on each nfs1 mount is counted once rather than four times
+  assert.Equal(t, naive.RPCOpStats["GETATTR"].Operations-3*13920, totals.RPCOpStats["GETATTR"].Operations)
+  assert.Greater(t, naive.Sends, totals.Sends)
+}
+
+func TestTotalsDelta(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    for _, mountpoint := range nfs1Mounts {
+      dev := findDevice(t, cur, mountpoint)
+      dev.NFSInfo.Age += 10
+      read := dev.NFSInfo.RPCOpStats["READ"]
+      read.Operations += 100
+      dev.NFSInfo.RPCOpStats["READ"] = read
+      xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+      xprt.RpcSends += 100
+    }
+  })
+
+  totals := delta.Totals(loadTestMountstats(t).Shared(loadTestMountinfo(t)))
+  assert.Equal(t, uint64(100), totals.RPCOpStats["READ"].Operations)
+  assert.Equal(t, uint64(100), totals.Sends)
+}
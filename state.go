@@ -0,0 +1,168 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// thresholds for the StateReport diagnoses
+const (
+  stateMinOps               = 10   // fewer ops than this aren't judged
+  stateLockContentionErrors = 0.05 // failed LOCKs, mostly denied, as a share of LOCKs
+  stateDelegStormPerOpen    = 0.5  // DELEGRETURNs for every open RPC
+  stateDelegStormPerSec     = 10.0 // DELEGRETURNs per second
+)
+
+// StateReport summarizes the NFSv4 state ops of a mount: opens and closes,
+// byte range locks and delegation returns. NFSv3 has none of these, its locks
+// go over NLM which mountstats doesn't count, so only the VFS counters are
+// filled in for it. Latencies are in milliseconds.
+// The balances are net differences of op counts, not state held on the
+// server: an OPEN that upgrades an open the client already has needs no
+// CLOSE of its own, a LOCK that extends a range no LOCKU, and over a delta
+// they're only the change within the interval.
+type StateReport struct {
+  Device      string
+  Mountpoint  string
+  SampleTime  time.Duration // the mount age, or the length of the interval
+  HasStateOps bool          // the mount lists the NFSv4 state ops
+  HasErrStats bool          // the per-op lines have the errors column, see NFSInfo
+
+  Opens              uint64 // OPEN and OPEN_NOATTR
+  Closes             uint64
+  Downgrades         uint64 // OPEN_DOWNGRADE
+  OpenBalance        int64  // Opens that didn't fail - Closes
+  OpensPerSec        float64
+  ClosesPerSec       float64
+  VfsOpens           uint64
+  OpenRPCsPerVfsOpen float64 // well below 1 when delegations or cached opens keep opens on the client
+  OpenErrors         uint64
+  Open               OpLatency // OPEN and OPEN_NOATTR together
+  Close              OpLatency
+
+  Locks              uint64 // LOCK
+  LockTests          uint64 // LOCKT
+  Unlocks            uint64 // LOCKU
+  LockBalance        int64  // Locks that didn't fail - Unlocks
+  LocksPerSec        float64
+  UnlocksPerSec      float64
+  LockErrors         uint64  // failed LOCKs, mostly denied by a conflicting lock
+  LockErrorRatio     float64 // LockErrors / Locks
+  VfsLocks           uint64
+  LockRPCsPerVfsLock float64 // LOCK, LOCKT and LOCKU per VFS lock call, 0 when locks stay local (local_lock, or a delegation)
+  Lock               OpLatency
+  Unlock             OpLatency
+
+  DelegReturns        uint64
+  DelegReturnsPerSec  float64
+  DelegReturnsPerOpen float64 // close to 1 when most delegations the server hands out get recalled
+  DelegReturn         OpLatency
+
+  LockContention  bool // enough LOCKs fail that applications are likely waiting on each other's locks
+  DelegationStorm bool // delegations are being returned about as fast as they're handed out
+}
+
+// StateReport summarizes the state ops since the mount was made, with rates
+// averaged over its Age.
+func (d *MountDevice) StateReport() StateReport {
+  return newStateReport(d.Device, d.Mountpoint, &d.NFSInfo, time.Duration(d.NFSInfo.Age)*time.Second)
+}
+
+// StateReport summarizes the state ops over the Interval of the delta.
+func (d *MountDeviceDelta) StateReport() StateReport {
+  return newStateReport(d.Device, d.Mountpoint, &d.NFSInfo, d.Interval)
+}
+
+// newStateReport summarizes the state ops in `info`, which are either
+// cumulative or a delta, over `sample`.
+func newStateReport(device, mountpoint string, info *NFSInfo, sample time.Duration) StateReport {
+  ops := info.RPCOpStats
+  _, hasState := ops["OPEN"]
+  r := StateReport{
+    Device: device,
+    Mountpoint: mountpoint,
+    SampleTime: sample,
+    HasStateOps: hasState,
+    HasErrStats: info.HasErrStats,
+    VfsOpens: info.Events.VfsOpen,
+    VfsLocks: info.Events.VfsLock,
+  }
+  seconds := sample.Seconds()
+
+  open := ops["OPEN"].add(ops["OPEN_NOATTR"])
+  r.Opens = open.Operations
+  r.Closes = ops["CLOSE"].Operations
+  r.Downgrades = ops["OPEN_DOWNGRADE"].Operations
+  r.OpensPerSec = perSecond(r.Opens, seconds)
+  r.ClosesPerSec = perSecond(r.Closes, seconds)
+  r.OpenRPCsPerVfsOpen = ratio(r.Opens, r.VfsOpens)
+  r.OpenErrors = open.ErrStats
+  r.Open = newOpLatency("OPEN", open)
+  r.Close = newOpLatency("CLOSE", ops["CLOSE"])
+  // a failed open or lock doesn't leave any state to close or unlock
+  r.OpenBalance = int64(clampedSub(r.Opens, r.OpenErrors)) - int64(r.Closes)
+
+  r.Locks = ops["LOCK"].Operations
+  r.LockTests = ops["LOCKT"].Operations
+  r.Unlocks = ops["LOCKU"].Operations
+  r.LocksPerSec = perSecond(r.Locks, seconds)
+  r.UnlocksPerSec = perSecond(r.Unlocks, seconds)
+  r.LockErrors = ops["LOCK"].ErrStats
+  r.LockErrorRatio = ratio(r.LockErrors, r.Locks)
+  r.LockBalance = int64(clampedSub(r.Locks, r.LockErrors)) - int64(r.Unlocks)
+  r.LockRPCsPerVfsLock = ratio(r.Locks+r.LockTests+r.Unlocks, r.VfsLocks)
+  r.Lock = newOpLatency("LOCK", ops["LOCK"])
+  r.Unlock = newOpLatency("LOCKU", ops["LOCKU"])
+
+  r.DelegReturns = ops["DELEGRETURN"].Operations
+  r.DelegReturnsPerSec = perSecond(r.DelegReturns, seconds)
+  r.DelegReturnsPerOpen = ratio(r.DelegReturns, r.Opens)
+  r.DelegReturn = newOpLatency("DELEGRETURN", ops["DELEGRETURN"])
+
+  r.LockContention = r.HasErrStats && r.Locks >= stateMinOps && r.LockErrorRatio >= stateLockContentionErrors
+  r.DelegationStorm = r.DelegReturns >= stateMinOps && r.DelegReturnsPerOpen >= stateDelegStormPerOpen && r.DelegReturnsPerSec >= stateDelegStormPerSec
+
+  return r
+}
+
+// Text renders the report in the style of the mountstats reports.
+func (r StateReport) Text() string {
+  var b strings.Builder
+
+  fmt.Fprintf(&b, "State ops for %s mounted on %s:\n", r.Device, r.Mountpoint)
+  if !r.HasStateOps {
+    fmt.Fprintf(&b, "  no NFSv4 state ops, VFS called nfs_file_open() %d times and nfs_lock() %d times\n", r.VfsOpens, r.VfsLocks)
+    return b.String()
+  }
+
+  fmt.Fprintf(&b, "\nOpens:\n")
+  fmt.Fprintf(&b, "  %d opens, %d closes, %d downgrades (%d net of closes)\n", r.Opens, r.Closes, r.Downgrades, r.OpenBalance)
+  fmt.Fprintf(&b, "  %.2f opens/s, %.2f closes/s, %.2f open RPCs per VFS open\n", r.OpensPerSec, r.ClosesPerSec, r.OpenRPCsPerVfsOpen)
+  if r.HasErrStats {
+    fmt.Fprintf(&b, "  %d opens failed\n", r.OpenErrors)
+  }
+  fmt.Fprintf(&b, "  open RTT: %f \topen execute time: %f (milliseconds)\n", r.Open.AvgRTT, r.Open.AvgExe)
+  fmt.Fprintf(&b, "  close RTT: %f \tclose execute time: %f (milliseconds)\n", r.Close.AvgRTT, r.Close.AvgExe)
+
+  fmt.Fprintf(&b, "\nLocks:\n")
+  fmt.Fprintf(&b, "  %d locks, %d unlocks, %d tests (%d net of unlocks)\n", r.Locks, r.Unlocks, r.LockTests, r.LockBalance)
+  fmt.Fprintf(&b, "  %.2f locks/s, %.2f unlocks/s, %.2f lock RPCs per VFS lock call\n", r.LocksPerSec, r.UnlocksPerSec, r.LockRPCsPerVfsLock)
+  if r.HasErrStats {
+    fmt.Fprintf(&b, "  %d locks failed (%.2f%%)\n", r.LockErrors, r.LockErrorRatio*100)
+  }
+  fmt.Fprintf(&b, "  lock RTT: %f \tlock execute time: %f (milliseconds)\n", r.Lock.AvgRTT, r.Lock.AvgExe)
+  fmt.Fprintf(&b, "  unlock RTT: %f \tunlock execute time: %f (milliseconds)\n", r.Unlock.AvgRTT, r.Unlock.AvgExe)
+
+  fmt.Fprintf(&b, "\nDelegations:\n")
+  fmt.Fprintf(&b, "  %d delegations returned, %.2f/s, %.2f per open\n", r.DelegReturns, r.DelegReturnsPerSec, r.DelegReturnsPerOpen)
+
+  if r.LockContention {
+    fmt.Fprintf(&b, "\nLOCKs are failing, applications are likely contending for the same locks\n")
+  }
+  if r.DelegationStorm {
+    fmt.Fprintf(&b, "\ndelegations are being recalled about as fast as they're handed out\n")
+  }
+
+  return b.String()
+}
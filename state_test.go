@@ -0,0 +1,101 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateReportSinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  r := findDevice(t, mounts, "/mnt/nfs1/docs").StateReport()
+  assert.True(t, r.HasStateOps)
+  assert.Equal(t, 258103*time.Second, r.SampleTime)
+
+  // OPEN: 916 and OPEN_NOATTR: 1401, with 374 and 12 errors, and CLOSE: 1921
+  assert.Equal(t, uint64(2317), r.Opens)
+  assert.Equal(t, uint64(1921), r.Closes)
+  assert.Equal(t, uint64(386), r.OpenErrors)
+  assert.Equal(t, int64(10), r.OpenBalance)
+  assert.InDelta(t, 2317.0/9263.0, r.OpenRPCsPerVfsOpen, 0.000001)
+  assert.InDelta(t, (1957.0+2792.0)/2317.0, r.Open.AvgRTT, 0.000001)
+
+  // LOCK: 12 12 0 3696 1344 0 22 22 0 and LOCKU: 12 12 0 3168 1344 0 26 27 0
+  assert.Equal(t, uint64(12), r.Locks)
+  assert.Equal(t, uint64(12), r.Unlocks)
+  assert.Equal(t, int64(0), r.LockBalance)
+  assert.InDelta(t, 22.0/12.0, r.Lock.AvgExe, 0.000001)
+  assert.InDelta(t, 27.0/12.0, r.Unlock.AvgExe, 0.000001)
+  assert.False(t, r.LockContention)
+
+  // DELEGRETURN: 1305
+  assert.Equal(t, uint64(1305), r.DelegReturns)
+  assert.InDelta(t, 1305.0/2317.0, r.DelegReturnsPerOpen, 0.000001)
+  assert.False(t, r.DelegationStorm)
+
+  text := r.Text()
+  assert.Contains(t, text, "2317 opens, 1921 closes, 1 downgrades (10 net of closes)\n")
+  assert.Contains(t, text, "12 locks, 12 unlocks, 0 tests (0 net of unlocks)\n")
+  assert.Contains(t, text, "1305 delegations returned")
+}
+
+func TestStateReportNFSv3(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  r := findDevice(t, mounts, "/mailhome6").StateReport()
+  assert.False(t, r.HasStateOps)
+  assert.Equal(t, uint64(5816728), r.VfsOpens)
+  assert.Equal(t, uint64(0), r.Opens)
+  assert.Contains(t, r.Text(), "no NFSv4 state ops")
+}
+
+func TestStateReportDelta(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mnt/nfs1/docs")
+    dev.NFSInfo.Age += 10
+    dev.NFSInfo.Events.VfsLock += 400
+    for op, counts := range map[string][2]uint64{
+      "OPEN": {500, 0},
+      "CLOSE": {480, 0},
+      "DELEGRETURN": {450, 0},
+      "LOCK": {200, 40},
+      "LOCKU": {150, 0},
+    } {
+      s := dev.NFSInfo.RPCOpStats[op]
+      s.Operations += counts[0]
+      s.ErrStats += counts[1]
+      dev.NFSInfo.RPCOpStats[op] = s
+    }
+  })
+
+  r := delta.GetMountMap()["/mnt/nfs1/docs"].StateReport()
+  assert.Equal(t, 10*time.Second, r.SampleTime)
+  assert.Equal(t, int64(20), r.OpenBalance)
+  assert.InDelta(t, 50.0, r.OpensPerSec, 0.000001)
+  assert.InDelta(t, 20.0, r.LocksPerSec, 0.000001)
+  assert.Equal(t, int64(10), r.LockBalance)
+  assert.InDelta(t, 0.2, r.LockErrorRatio, 0.000001)
+  assert.InDelta(t, 350.0/400.0, r.LockRPCsPerVfsLock, 0.000001)
+  assert.InDelta(t, 45.0, r.DelegReturnsPerSec, 0.000001)
+  assert.True(t, r.LockContention)
+  assert.True(t, r.DelegationStorm)
+
+  text := r.Text()
+  assert.Contains(t, text, "40 locks failed (20.00%)\n")
+  assert.Contains(t, text, "contending for the same locks")
+  assert.Contains(t, text, "recalled about as fast")
+}
+
+func TestStateReportIdle(t *testing.T) {
+  info := nfsmountstats.NFSInfo{RPCOpStats: map[string]nfsmountstats.RPCOpStat{"OPEN": {}}}
+  dev := nfsmountstats.MountDevice{Mountpoint: "/idle", NFSInfo: info}
+
+  r := dev.StateReport()
+  assert.True(t, r.HasStateOps)
+  assert.Equal(t, 0.0, r.OpenRPCsPerVfsOpen)
+  assert.Equal(t, 0.0, r.Open.AvgRTT)
+  assert.False(t, r.DelegationStorm)
+}
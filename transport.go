@@ -0,0 +1,138 @@
+package nfsmountstats
+
+import (
+	"fmt"
+	"time"
+)
+
+// TransportHealth turns the cumulative xprt: counters into the averages per
+// RPC that show whether a transport is saturated, either since the mount was
+// made or over an interval between two snapshots.
+// The queue averages come from counters the kernel adds the current queue
+// length to every time it sends a request, so dividing by the sends gives the
+// average length a request saw. Fields the protocol doesn't have are 0, eg:
+// there are no slot or queue counters on RDMA, and UDP never reconnects.
+type TransportHealth struct {
+  Protocol    string        // "" if the mount has no xprt: line
+  SampleTime  time.Duration // the time the rates are averaged over
+  Sends       uint64
+  Receives    uint64
+  SendsPerSec float64
+
+  AvgBacklog      float64 // requests waiting for a slot
+  AvgInflight     float64 // requests on the wire, waiting for a reply
+  AvgSendingQueue float64 // requests queued to be sent, statvers 1.1+
+  AvgPendingQueue float64 // requests sent and waiting for a reply, statvers 1.1+
+
+  HasSlotStats  bool    // the transport reports MaxRPCSlots, statvers 1.1+
+  MaxRPCSlots   uint64  // high water mark of slots in use since the mount was made, not the slot table size
+  PeakSlotShare float64 // AvgInflight / MaxRPCSlots, the average as a share of the peak, not utilization
+
+  BadXids       uint64  // replies that didn't match any request
+  BadXidRatio   float64 // BadXids / Receives
+  BadXidsPerSec float64
+
+  Reconnects  uint64 // connects after the first one, or all connects over an interval
+  ConnectTime uint64 // the connect_time counter as the kernel reports it
+  IdleTime    uint64 // seconds since the transport was last used
+}
+
+// TransportHealth computes the transport health since the mount was made, with
+// rates averaged over its Age.
+func (i *NFSInfo) TransportHealth() TransportHealth {
+  return newTransportHealth(i.Transport, time.Duration(i.Age)*time.Second, true)
+}
+
+// TransportHealth computes the transport health over the Interval of the
+// delta. For New or remounted devices the counters are since mount, and so is
+// the reconnect count.
+func (d *MountDeviceDelta) TransportHealth() TransportHealth {
+  return newTransportHealth(d.NFSInfo.Transport, d.Interval, d.sinceMount())
+}
+
+// newTransportHealth computes the health of the counters in `t`, which are
+// either cumulative or a delta, over `sample`. `sinceMount` means the counters
+// include the initial connect, which isn't a reconnect.
+func newTransportHealth(t NFSTransportCounters, sample time.Duration, sinceMount bool) TransportHealth {
+  health := TransportHealth{SampleTime: sample}
+
+  var connects, backlog, inflight, sending, pending uint64
+  switch t := t.(type) {
+  case *NFSTransportCountersUDP:
+    health.Protocol = t.Protocol()
+    health.Sends, health.Receives, health.BadXids = t.RpcSends, t.RpcReceives, t.BadXids
+    backlog, inflight = t.BacklogUtil, t.InflightSends
+  case *NFSTransportCountersTCP:
+    health.Protocol = t.Protocol()
+    health.Sends, health.Receives, health.BadXids = t.RpcSends, t.RpcReceives, t.BadXids
+    backlog, inflight = t.BacklogUtil, t.InflightSends
+    sending, pending = t.CumSendingQueue, t.CumPendingQueue
+    health.MaxRPCSlots = t.MaxRPCSlots
+    health.HasSlotStats = t.MaxRPCSlots > 0
+    connects, health.ConnectTime, health.IdleTime = t.ConnectCount, t.ConnectTime, t.IdleTime
+  case *NFSTransportCountersRDMA:
+    health.Protocol = t.Protocol()
+    health.Sends, health.Receives, health.BadXids = t.RpcSends, t.RpcReceives, t.BadXids
+    backlog = t.BacklogUtil
+    connects, health.ConnectTime, health.IdleTime = t.ConnectCount, t.ConnectTime, t.IdleTime
+  default:
+    return health
+  }
+
+  seconds := sample.Seconds()
+  health.SendsPerSec = perSecond(health.Sends, seconds)
+  health.BadXidsPerSec = perSecond(health.BadXids, seconds)
+  health.BadXidRatio = ratio(health.BadXids, health.Receives)
+
+  health.AvgBacklog = ratio(backlog, health.Sends)
+  health.AvgInflight = ratio(inflight, health.Sends)
+  health.AvgSendingQueue = ratio(sending, health.Sends)
+  health.AvgPendingQueue = ratio(pending, health.Sends)
+  if health.HasSlotStats {
+    health.PeakSlotShare = health.AvgInflight / float64(health.MaxRPCSlots)
+  }
+
+  health.Reconnects = connects
+  if sinceMount {
+    health.Reconnects = clampedSub(connects, 1)
+  }
+
+  return health
+}
+
+// TransportKey identifies the transport the mount sends its RPCs over, eg:
+// `tcp 10.0.47.9 port 840`. Mounts of the same server share a transport
+// unless they were mounted with nconnect or nosharetransport, in which case
+// their local ports tell them apart. The port is 0 for transports that aren't
+// bound.
+// Returns "" for a mount without an xprt: line.
+func (d *MountDevice) TransportKey() string {
+  return transportKey(d.Device, d.NFSInfo.Options, d.NFSInfo.Transport)
+}
+
+// TransportKey identifies the transport of a delta, see MountDevice.TransportKey.
+func (d *MountDeviceDelta) TransportKey() string {
+  return transportKey(d.Device, d.NFSInfo.Options, d.NFSInfo.Transport)
+}
+
+// transportKey builds the key of the transport `t` of the mount of `device`.
+func transportKey(device string, options NFSMountOptions, t NFSTransportCounters) string {
+  var port uint64
+  switch t := t.(type) {
+  case *NFSTransportCountersUDP:
+    port = t.Port
+  case *NFSTransportCountersTCP:
+    port = t.Port
+  case *NFSTransportCountersRDMA:
+    port = t.Port
+  default:
+    return ""
+  }
+
+  server := device
+  if addr, err := newNFSServerAddress(device, options); err == nil {
+    server = addr.Key()
+  }
+
+  return fmt.Sprintf("%s %s port %d", t.Protocol(), server, port)
+}
@@ -0,0 +1,76 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportHealthSinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // xprt: tcp 840 1 1 0 0 1013715537 1013715535 2 18247684089 0 1417 59765520263 15660436504
+  health := findDevice(t, mounts, "/mailhome6").NFSInfo.TransportHealth()
+  assert.Equal(t, "tcp", health.Protocol)
+  assert.Equal(t, 2919118*time.Second, health.SampleTime)
+  assert.InDelta(t, 347.2677, health.SendsPerSec, 0.0001)
+  assert.InDelta(t, 0.0, health.AvgBacklog, 0.0001)
+  assert.InDelta(t, 18.0008, health.AvgInflight, 0.0001)
+  assert.InDelta(t, 58.9569, health.AvgSendingQueue, 0.0001)
+  assert.InDelta(t, 15.4486, health.AvgPendingQueue, 0.0001)
+  assert.True(t, health.HasSlotStats)
+  assert.Equal(t, uint64(1417), health.MaxRPCSlots)
+  assert.InDelta(t, 0.0127, health.PeakSlotShare, 0.0001)
+  assert.Equal(t, uint64(2), health.BadXids)
+  // the initial connect isn't a reconnect
+  assert.Equal(t, uint64(0), health.Reconnects)
+
+  udp := findDevice(t, mounts, "/mailhome5udp").NFSInfo.TransportHealth()
+  assert.Equal(t, "udp", udp.Protocol)
+  assert.InDelta(t, 18.0008, udp.AvgInflight, 0.0001)
+  assert.False(t, udp.HasSlotStats)
+  assert.Equal(t, 0.0, udp.PeakSlotShare)
+
+  rdma := findDevice(t, mounts, "/mailhome5rdma").NFSInfo.TransportHealth()
+  assert.Equal(t, "rdma", rdma.Protocol)
+  assert.Equal(t, 0.0, rdma.AvgInflight)
+  assert.Equal(t, uint64(1013715537), rdma.Sends)
+}
+
+func TestTransportHealthInterval(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    xprt := dev.NFSInfo.Transport.(*nfsmountstats.NFSTransportCountersTCP)
+    xprt.RpcSends += 1000
+    xprt.RpcReceives += 1000
+    xprt.BadXids += 10
+    xprt.ConnectCount += 2
+    xprt.BacklogUtil += 3000
+    xprt.InflightSends += 708500
+    xprt.CumSendingQueue += 5000
+    xprt.CumPendingQueue += 700000
+  })
+
+  health := delta.GetMountMap()["/mailhome6"].TransportHealth()
+  assert.Equal(t, 10*time.Second, health.SampleTime)
+  assert.InDelta(t, 100.0, health.SendsPerSec, 0.0001)
+  assert.InDelta(t, 3.0, health.AvgBacklog, 0.0001)
+  assert.InDelta(t, 708.5, health.AvgInflight, 0.0001)
+  assert.InDelta(t, 5.0, health.AvgSendingQueue, 0.0001)
+  assert.InDelta(t, 700.0, health.AvgPendingQueue, 0.0001)
+  assert.InDelta(t, 0.5, health.PeakSlotShare, 0.0001)
+  assert.InDelta(t, 0.01, health.BadXidRatio, 0.0001)
+  assert.InDelta(t, 1.0, health.BadXidsPerSec, 0.0001)
+  // every connect in an interval is a reconnect
+  assert.Equal(t, uint64(2), health.Reconnects)
+}
+
+func TestTransportHealthNoTransport(t *testing.T) {
+  info := nfsmountstats.NFSInfo{Age: 100}
+  health := info.TransportHealth()
+  assert.Equal(t, "", health.Protocol)
+  assert.Equal(t, 0.0, health.AvgInflight)
+}
@@ -0,0 +1,147 @@
+package nfsmountstats
+
+// Workload is the kind of I/O a mount does.
+type Workload string
+
+const (
+  WorkloadIdle           Workload = "idle"            // nothing ran
+  WorkloadMetadata       Workload = "metadata"        // GETATTR, LOOKUP, ACCESS and friends rather than reads and writes
+  WorkloadStreamingRead  Workload = "streaming-read"  // mostly reads, close to rsize each
+  WorkloadStreamingWrite Workload = "streaming-write" // mostly writes, close to wsize each
+  WorkloadSmallIO        Workload = "small-io"        // reads and writes well below rsize and wsize, usually random
+  WorkloadLocking        Workload = "locking"         // a lot of locking for the work done
+  WorkloadMixed          Workload = "mixed"           // large reads and writes both
+)
+
+// thresholds for ClassifyWorkload
+const (
+  workloadLockShare       = 0.1   // lock requests as a share of ops
+  workloadMetadataPerData = 4.0   // metadata ops for every data op
+  workloadDirectionShare  = 0.8   // the share of bytes read or written to be mostly one way
+  workloadStreamingSize   = 0.5   // the average size as a ratio of rsize or wsize to be streaming
+  workloadDefaultIOSize   = 65536 // rsize and wsize when the mount options don't say
+)
+
+// ops counted as data, metadata or locking, anything else (COMMIT, the NFSv4
+// session and state ops) is housekeeping and left out
+var (
+  workloadDataOps = map[string]bool{"READ": true, "WRITE": true, "READ_PLUS": true}
+  workloadLockOps = map[string]bool{"LOCK": true, "LOCKT": true, "LOCKU": true}
+  workloadMetadataOps = map[string]bool{
+    "GETATTR": true, "SETATTR": true, "LOOKUP": true, "LOOKUPP": true, "LOOKUP_ROOT": true,
+    "ACCESS": true, "READLINK": true, "CREATE": true, "MKDIR": true, "SYMLINK": true,
+    "MKNOD": true, "REMOVE": true, "RMDIR": true, "RENAME": true, "LINK": true,
+    "READDIR": true, "READDIRPLUS": true, "FSSTAT": true, "FSINFO": true, "PATHCONF": true,
+    "STATFS": true, "OPEN": true, "OPEN_NOATTR": true, "OPEN_CONFIRM": true,
+    "OPEN_DOWNGRADE": true, "CLOSE": true, "GETACL": true, "SETACL": true,
+    "GETXATTR": true, "SETXATTR": true, "LISTXATTRS": true, "REMOVEXATTR": true,
+  }
+)
+
+// WorkloadProfile labels a mount with the kind of I/O it does, along with the
+// ratios the label comes from. The sizes are bytes on the wire per op, which
+// include the rpc headers, so they're a little over the size of the data.
+// Locks taken on NFSv3 go over NLM, which mountstats doesn't count, so the
+// lock requests are whichever is more of the LOCK ops and the VFS lock calls.
+// Random and sequential I/O can't be told apart from the counters, small I/O
+// is usually random and large I/O usually sequential.
+type WorkloadProfile struct {
+  Workload        Workload
+  Operations      uint64 // data, metadata and lock ops, housekeeping is left out
+  DataOps         uint64
+  MetadataOps     uint64
+  LockRequests    uint64
+  MetadataPerData float64 // MetadataOps / DataOps
+  LockShare       float64 // LockRequests / Operations
+
+  ReadBytes      uint64  // received by READs
+  WriteBytes     uint64  // sent by WRITEs
+  ReadShare      float64 // ReadBytes / (ReadBytes + WriteBytes)
+  AvgReadSize    float64
+  AvgWriteSize   float64
+  Rsize          uint64  // from the mount options, 0 if they don't say
+  Wsize          uint64
+  ReadSizeRatio  float64 // AvgReadSize / Rsize, or the default of 64KiB
+  WriteSizeRatio float64 // AvgWriteSize / Wsize, or the default of 64KiB
+}
+
+// ClassifyWorkload profiles the workload of the mount. Works the same since
+// mount and over a MountDeviceDelta, as its NFSInfo holds the difference of the
+// counters, and the latter is what shows what a mount is doing now.
+func (i *NFSInfo) ClassifyWorkload() WorkloadProfile {
+  var p WorkloadProfile
+  var readOps, writeOps, lockOps uint64
+  for op, s := range i.RPCOpStats {
+    switch {
+    case workloadDataOps[op]:
+      p.DataOps += s.Operations
+      if op == "WRITE" {
+        writeOps += s.Operations
+        p.WriteBytes += s.BytesSent
+      } else {
+        readOps += s.Operations
+        p.ReadBytes += s.BytesReceived
+      }
+    case workloadLockOps[op]:
+      lockOps += s.Operations
+    case workloadMetadataOps[op]:
+      p.MetadataOps += s.Operations
+    }
+  }
+
+  p.LockRequests = max(lockOps, i.Events.VfsLock)
+  p.Operations = p.DataOps + p.MetadataOps + p.LockRequests
+  p.MetadataPerData = ratio(p.MetadataOps, p.DataOps)
+  p.LockShare = ratio(p.LockRequests, p.Operations)
+
+  p.ReadShare = ratio(p.ReadBytes, p.ReadBytes+p.WriteBytes)
+  p.AvgReadSize = ratio(p.ReadBytes, readOps)
+  p.AvgWriteSize = ratio(p.WriteBytes, writeOps)
+  p.Rsize, _ = i.Options.Uint("rsize")
+  p.Wsize, _ = i.Options.Uint("wsize")
+  p.ReadSizeRatio = p.AvgReadSize / float64(ioSize(p.Rsize))
+  p.WriteSizeRatio = p.AvgWriteSize / float64(ioSize(p.Wsize))
+
+  p.Workload = p.classify()
+
+  return p
+}
+
+// classify picks the label for the ratios.
+func (p WorkloadProfile) classify() Workload {
+  if p.Operations == 0 {
+    return WorkloadIdle
+  }
+  if p.LockShare >= workloadLockShare {
+    return WorkloadLocking
+  }
+  if p.DataOps == 0 || p.MetadataPerData >= workloadMetadataPerData {
+    return WorkloadMetadata
+  }
+
+  streamingRead := p.ReadSizeRatio >= workloadStreamingSize
+  streamingWrite := p.WriteSizeRatio >= workloadStreamingSize
+  switch {
+  case p.ReadShare >= workloadDirectionShare:
+    if streamingRead {
+      return WorkloadStreamingRead
+    }
+  case p.ReadShare <= 1-workloadDirectionShare:
+    if streamingWrite {
+      return WorkloadStreamingWrite
+    }
+  case streamingRead || streamingWrite:
+    return WorkloadMixed
+  }
+
+  return WorkloadSmallIO
+}
+
+// ioSize returns `size`, or the default if it's unknown.
+func ioSize(size uint64) uint64 {
+  if size == 0 {
+    return workloadDefaultIOSize
+  }
+
+  return size
+}
@@ -0,0 +1,96 @@
+package nfsmountstats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jessegalley/nfsmountstats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyWorkloadSinceMount(t *testing.T) {
+  mounts := loadTestMountstats(t)
+
+  // a mail server, a lot more metadata than data
+  p := findDevice(t, mounts, "/mailhome6").NFSInfo.ClassifyWorkload()
+  assert.Equal(t, nfsmountstats.WorkloadMetadata, p.Workload)
+  assert.Equal(t, uint64(7011605), p.DataOps)
+  assert.Equal(t, uint64(38081510), p.MetadataOps)
+  assert.InDelta(t, 5.431212, p.MetadataPerData, 0.000001)
+  assert.Equal(t, uint64(32768), p.Rsize)
+  assert.Equal(t, uint64(16384), p.Wsize)
+  assert.InDelta(t, 94672388276.0/6438446.0, p.AvgReadSize, 0.000001)
+  assert.InDelta(t, 0.448737, p.ReadSizeRatio, 0.000001)
+  assert.InDelta(t, 0.820495, p.WriteSizeRatio, 0.000001)
+
+  // LOCK: 12 and LOCKU: 12, and no VFS lock calls counted
+  p = findDevice(t, mounts, "/mnt/nfs1/docs").NFSInfo.ClassifyWorkload()
+  assert.Equal(t, nfsmountstats.WorkloadMetadata, p.Workload)
+  assert.Equal(t, uint64(24), p.LockRequests)
+  assert.Equal(t, uint64(997+28520+24), p.Operations)
+}
+
+// workloadInfo returns an NFSInfo that ran `ops` with 32k rsize and wsize.
+func workloadInfo(ops map[string]nfsmountstats.RPCOpStat) *nfsmountstats.NFSInfo {
+  return &nfsmountstats.NFSInfo{
+    Options: nfsmountstats.ParseNFSMountOptions("rw,vers=3,rsize=32768,wsize=32768"),
+    RPCOpStats: ops,
+  }
+}
+
+func TestClassifyWorkload(t *testing.T) {
+  tests := []struct {
+    name     string
+    ops      map[string]nfsmountstats.RPCOpStat
+    vfsLock  uint64
+    workload nfsmountstats.Workload
+  }{
+    {"idle", map[string]nfsmountstats.RPCOpStat{"NULL": {Operations: 1}}, 0, nfsmountstats.WorkloadIdle},
+    {"streaming read", map[string]nfsmountstats.RPCOpStat{
+      "READ": {Operations: 100, BytesReceived: 100 * 32768},
+      "GETATTR": {Operations: 10},
+    }, 0, nfsmountstats.WorkloadStreamingRead},
+    {"streaming write", map[string]nfsmountstats.RPCOpStat{
+      "WRITE": {Operations: 100, BytesSent: 100 * 30000},
+      "READ": {Operations: 1, BytesReceived: 4096},
+    }, 0, nfsmountstats.WorkloadStreamingWrite},
+    {"small reads", map[string]nfsmountstats.RPCOpStat{
+      "READ": {Operations: 100, BytesReceived: 100 * 4096},
+    }, 0, nfsmountstats.WorkloadSmallIO},
+    {"large both ways", map[string]nfsmountstats.RPCOpStat{
+      "READ": {Operations: 100, BytesReceived: 100 * 32768},
+      "WRITE": {Operations: 100, BytesSent: 100 * 32768},
+    }, 0, nfsmountstats.WorkloadMixed},
+    {"metadata only", map[string]nfsmountstats.RPCOpStat{
+      "LOOKUP": {Operations: 100},
+    }, 0, nfsmountstats.WorkloadMetadata},
+    {"nlm locks", map[string]nfsmountstats.RPCOpStat{
+      "READ": {Operations: 100, BytesReceived: 100 * 4096},
+    }, 20, nfsmountstats.WorkloadLocking},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      info := workloadInfo(test.ops)
+      info.Events.VfsLock = test.vfsLock
+      assert.Equal(t, test.workload, info.ClassifyWorkload().Workload)
+    })
+  }
+}
+
+func TestClassifyWorkloadDelta(t *testing.T) {
+  delta := loadTestDelta(t, 10*time.Second, func(cur *nfsmountstats.Mountstats) {
+    dev := findDevice(t, cur, "/mailhome6")
+    dev.NFSInfo.Age += 10
+    read := dev.NFSInfo.RPCOpStats["READ"]
+    read.Operations += 1000
+    read.BytesReceived += 1000 * 32768
+    dev.NFSInfo.RPCOpStats["READ"] = read
+  })
+
+  // a metadata mount since mount, streaming reads right now
+  p := delta.GetMountMap()["/mailhome6"].NFSInfo.ClassifyWorkload()
+  assert.Equal(t, nfsmountstats.WorkloadStreamingRead, p.Workload)
+  assert.Equal(t, 1.0, p.ReadShare)
+  assert.Equal(t, 1.0, p.ReadSizeRatio)
+}